@@ -1,15 +1,29 @@
 package applications
 
 import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
 	v1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/client/clientset/versioned"
 	"github.com/jenkins-x/jx/pkg/cmd/clients"
+	"github.com/jenkins-x/jx/pkg/config"
 	"github.com/jenkins-x/jx/pkg/flagger"
+	"github.com/jenkins-x/jx/pkg/gits"
 	"github.com/jenkins-x/jx/pkg/kube"
 	"github.com/jenkins-x/jx/pkg/kube/naming"
 	"github.com/jenkins-x/jx/pkg/kube/services"
+	"github.com/jenkins-x/jx/pkg/log"
 	"github.com/jenkins-x/jx/pkg/util"
 	"github.com/pkg/errors"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
@@ -17,6 +31,9 @@ import (
 // Deployment represents an application deployment in a single environment
 type Deployment struct {
 	*appsv1.Deployment
+
+	// url the URL resolved by List.ResolveURLs
+	url *string
 }
 
 // Environment represents an environment in which an application has been
@@ -32,9 +49,13 @@ type Application struct {
 	Environments map[string]Environment
 }
 
-// List is a collection of applications
+// List is a collection of applications. A List is read-only once it has been returned by GetApplications or
+// ListApplications so it can be shared between goroutines
 type List struct {
 	Items []Application
+
+	// DevRequirements the requirements of the development environment, if it was installed via boot
+	DevRequirements *config.RequirementsConfig
 }
 
 // Environments loops through all applications in a list and returns a map with
@@ -53,130 +74,833 @@ func (l List) Environments() map[string]v1.Environment {
 	return envs
 }
 
+// EnvironmentColumns returns the names of the permanent environments of the applications in the list in promotion
+// order, which is the order in which renderers should display the environments as columns
+func (l List) EnvironmentColumns() []string {
+	envs := []v1.Environment{}
+	for _, env := range l.Environments() {
+		if env.Spec.Kind.IsPermanent() {
+			envs = append(envs, env)
+		}
+	}
+	kube.SortEnvironments(envs)
+	names := make([]string, 0, len(envs))
+	for _, env := range envs {
+		names = append(names, env.Name)
+	}
+	return names
+}
+
+// ServiceDomainTemplate returns the template of the host names of the services exposed in the environment in the
+// form {{.Service}}.<namespace>.<domain>, using the ingress domain of the environment in the requirements of the
+// development environment. An empty string is returned if the environment or its domain are unknown
+func (l List) ServiceDomainTemplate(env string) string {
+	e, ok := l.Environments()[env]
+	if !ok || l.DevRequirements == nil {
+		return ""
+	}
+	domain := l.DevRequirements.Ingress.Domain
+	if envConfig, err := l.DevRequirements.Environment(env); err == nil && envConfig.Ingress.Domain != "" {
+		domain = envConfig.Ingress.Domain
+	}
+	if domain == "" {
+		return ""
+	}
+	return fmt.Sprintf("{{.Service}}.%s.%s", e.Spec.Namespace, domain)
+}
+
+// Registries returns the sorted unique registry hosts of the container images used by the deployments of all
+// applications in the list
+func (l List) Registries() []string {
+	registries := map[string]bool{}
+	for _, a := range l.Items {
+		for _, env := range a.Environments {
+			for _, d := range env.Deployments {
+				podSpec := d.Deployment.Spec.Template.Spec
+				for _, containers := range [][]corev1.Container{podSpec.InitContainers, podSpec.Containers} {
+					for _, c := range containers {
+						if c.Image != "" {
+							registries[kube.ImageRegistry(c.Image)] = true
+						}
+					}
+				}
+			}
+		}
+	}
+
+	answer := make([]string, 0, len(registries))
+	for registry := range registries {
+		answer = append(answer, registry)
+	}
+	sort.Strings(answer)
+	return answer
+}
+
+// Undeployed returns the applications which have no deployment in any environment, e.g. because they have never
+// been promoted
+func (l List) Undeployed() []Application {
+	answer := []Application{}
+	for _, a := range l.Items {
+		deployed := false
+		for _, env := range a.Environments {
+			if len(env.Deployments) > 0 {
+				deployed = true
+				break
+			}
+		}
+		if !deployed {
+			answer = append(answer, a)
+		}
+	}
+	return answer
+}
+
+// Unhealthy returns a List of the applications which have at least one deployment with fewer ready replicas than the
+// fraction of the desired replicas, see Deployment.IsHealthy
+func (l List) Unhealthy(minReadyFraction float64) List {
+	answer := List{
+		Items:           []Application{},
+		DevRequirements: l.DevRequirements,
+	}
+	for _, a := range l.Items {
+		if a.hasUnhealthyDeployment(minReadyFraction) {
+			answer.Items = append(answer.Items, a)
+		}
+	}
+	return answer
+}
+
+// ResolveURLs returns a copy of the list whose deployments have their URLs resolved, listing the services and
+// ingresses of each namespace only once, so that Deployment.URL does not have to look up the service of each
+// deployment. The list itself is not modified so it can still be shared between goroutines
+func (l List) ResolveURLs(kc kubernetes.Interface) (List, error) {
+	answer := List{
+		Items:           make([]Application, 0, len(l.Items)),
+		DevRequirements: l.DevRequirements,
+	}
+	servicesByNamespace := map[string]map[string]*corev1.Service{}
+	ingressesByNamespace := map[string]map[string]*extensionsv1beta1.Ingress{}
+	for _, a := range l.Items {
+		app := Application{a.SourceRepository, make(map[string]Environment, len(a.Environments))}
+		for name, env := range a.Environments {
+			deployments := make([]Deployment, len(env.Deployments))
+			copy(deployments, env.Deployments)
+			for i := range deployments {
+				d := &deployments[i]
+				ns := d.Deployment.Namespace
+				if _, ok := servicesByNamespace[ns]; !ok {
+					svcs, err := services.GetServices(kc, ns)
+					if err != nil {
+						return l, errors.Wrapf(err, "listing the services in namespace %s", ns)
+					}
+					servicesByNamespace[ns] = svcs
+					ingresses, err := kc.ExtensionsV1beta1().Ingresses(ns).List(metav1.ListOptions{})
+					if err != nil {
+						return l, errors.Wrapf(err, "listing the ingresses in namespace %s", ns)
+					}
+					ingressesByNamespace[ns] = map[string]*extensionsv1beta1.Ingress{}
+					for j := range ingresses.Items {
+						ingressesByNamespace[ns][ingresses.Items[j].Name] = &ingresses.Items[j]
+					}
+				}
+				url := ""
+				if svc := servicesByNamespace[ns][a.Name()]; svc != nil {
+					url = services.ServiceProtocolURL(svc, ingressesByNamespace[ns][a.Name()])
+				}
+				d.url = &url
+			}
+			env.Deployments = deployments
+			app.Environments[name] = env
+		}
+		answer.Items = append(answer.Items, app)
+	}
+	return answer, nil
+}
+
 // Name returns the application name
 func (a Application) Name() string {
 	return naming.ToValidName(a.SourceRepository.Spec.Repo)
 }
 
+// DeployedEnvironmentCount returns the number of environments which contain at least one deployment of the
+// application
+func (a Application) DeployedEnvironmentCount() int {
+	count := 0
+	for _, env := range a.Environments {
+		if len(env.Deployments) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// MissingEnvironments returns the names of the given permanent environments which do not contain a deployment of the
+// application, in the order they are given, e.g. to find the environments an application has not been promoted to yet
+func (a Application) MissingEnvironments(allEnvs []string) []string {
+	answer := []string{}
+	for _, name := range allEnvs {
+		env, ok := a.Environments[name]
+		if ok && env.IsPreview() {
+			continue
+		}
+		if len(env.Deployments) == 0 {
+			answer = append(answer, name)
+		}
+	}
+	return answer
+}
+
+// hasUnhealthyDeployment returns true if any of the deployments of the application is not healthy
+func (a Application) hasUnhealthyDeployment(minReadyFraction float64) bool {
+	for _, env := range a.Environments {
+		for _, d := range env.Deployments {
+			if !d.IsHealthy(minReadyFraction) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // IsPreview returns true if the environment is a preview environment
 func (e Environment) IsPreview() bool {
 	return e.Environment.Spec.Kind == v1.EnvironmentKindTypePreview
 }
 
+// PullRequestNumber returns the number of the pull request of a preview environment, falling back to the last path
+// segment of its pull request URL. It returns an empty string for other environments
+func (e Environment) PullRequestNumber() string {
+	if !e.IsPreview() {
+		return ""
+	}
+	if number := e.Environment.Spec.PreviewGitSpec.Name; number != "" {
+		return number
+	}
+	prURL := strings.TrimSuffix(e.Environment.Spec.PullRequestURL, "/")
+	number := prURL[strings.LastIndex(prURL, "/")+1:]
+	if _, err := strconv.Atoi(number); err != nil {
+		return ""
+	}
+	return number
+}
+
+// PullRequestAuthor returns the user name of the author of the pull request of a preview environment, or an empty
+// string for other environments
+func (e Environment) PullRequestAuthor() string {
+	if !e.IsPreview() {
+		return ""
+	}
+	return e.Environment.Spec.PreviewGitSpec.User.Username
+}
+
+// PullRequestBranch returns the source branch of the pull request of a preview environment, or an empty string for
+// other environments
+func (e Environment) PullRequestBranch() string {
+	if !e.IsPreview() {
+		return ""
+	}
+	return e.Environment.Spec.Source.Ref
+}
+
+// IsRemote returns true if the environment runs in a remote cluster, so its deployments were fetched from that cluster
+func (e Environment) IsRemote() bool {
+	return e.Environment.Spec.RemoteCluster
+}
+
+// GitSourceURL returns the URL of the git repository the environment is deployed from via GitOps, or an empty string
+// if it is not a GitOps environment
+func (e Environment) GitSourceURL() string {
+	return e.Environment.Spec.Source.URL
+}
+
 // Version returns the deployment version
 func (d Deployment) Version() string {
 	return kube.GetVersion(&d.Deployment.ObjectMeta)
 }
 
+// Annotation returns the value of the annotation of the deployment, or an empty string if it is not present
+func (d Deployment) Annotation(key string) string {
+	return d.Deployment.Annotations[key]
+}
+
+// Label returns the value of the label of the deployment, or an empty string if it is not present
+func (d Deployment) Label(key string) string {
+	return d.Deployment.Labels[key]
+}
+
+// Replicas returns the desired, current and ready number of replicas, the desired replicas default to 1 if not
+// specified
+func (d Deployment) Replicas() (desired, current, ready int32) {
+	desired = 1
+	if d.Deployment.Spec.Replicas != nil {
+		desired = *d.Deployment.Spec.Replicas
+	}
+	return desired, d.Deployment.Status.Replicas, d.Deployment.Status.ReadyReplicas
+}
+
 // Pods returns the ratio of pods that are ready/replicas
 func (d Deployment) Pods() string {
 	pods := ""
-	ready := d.Deployment.Status.ReadyReplicas
+	desired, _, ready := d.Replicas()
 
-	if d.Deployment.Spec.Replicas != nil && ready > 0 {
-		replicas := util.Int32ToA(*d.Deployment.Spec.Replicas)
-		pods = util.Int32ToA(ready) + "/" + replicas
+	if ready > 0 {
+		pods = util.Int32ToA(ready) + "/" + util.Int32ToA(desired)
 	}
 
 	return pods
 }
 
-// URL returns a deployment URL
+// IsHealthy returns true if the fraction of ready replicas out of the desired replicas is at least minReadyFraction,
+// tolerating a surge of ready replicas or a small shortfall during a rollout. The desired replicas default to 1 if
+// not specified
+func (d Deployment) IsHealthy(minReadyFraction float64) bool {
+	desired, _, ready := d.Replicas()
+	if ready >= desired {
+		return true
+	}
+	return float64(ready)/float64(desired) >= minReadyFraction
+}
+
+// LastRolloutTime returns the time the deployment last progressed, i.e. when a new ReplicaSet was created or scaled
+// up, as recorded on its Progressing condition. It returns the zero time if the deployment has no such condition
+func (d Deployment) LastRolloutTime() time.Time {
+	for _, c := range d.Deployment.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing {
+			return c.LastUpdateTime.Time
+		}
+	}
+	return time.Time{}
+}
+
+// sidecarContainerNames the names of the containers injected into pods by service meshes and proxies, which are not
+// part of the application itself
+var sidecarContainerNames = map[string]bool{
+	"istio-init":     true,
+	"istio-proxy":    true,
+	"linkerd-init":   true,
+	"linkerd-proxy":  true,
+	"cloudsql-proxy": true,
+}
+
+// ContainerResources returns the CPU and memory requests and limits of the containers of the deployment's pod
+// template keyed by container name, skipping known sidecar containers
+func (d Deployment) ContainerResources() map[string]corev1.ResourceRequirements {
+	answer := map[string]corev1.ResourceRequirements{}
+	for _, c := range d.Deployment.Spec.Template.Spec.Containers {
+		if sidecarContainerNames[c.Name] {
+			continue
+		}
+		answer[c.Name] = c.Resources
+	}
+	return answer
+}
+
+// PrimaryContainer returns the main container of the deployment's pod template, which is the container named by the
+// jenkins.io/primary-container annotation if present, otherwise the first container which is not a known sidecar
+func (d Deployment) PrimaryContainer() *corev1.Container {
+	containers := d.Deployment.Spec.Template.Spec.Containers
+	if name := d.Annotation(kube.AnnotationPrimaryContainer); name != "" {
+		for i := range containers {
+			if containers[i].Name == name {
+				return &containers[i]
+			}
+		}
+		log.Logger().Debugf("deployment %s has no primary container %s", d.Deployment.Name, name)
+	}
+	for i := range containers {
+		if !sidecarContainerNames[containers[i].Name] {
+			return &containers[i]
+		}
+	}
+	return nil
+}
+
+// PrimaryImageTag returns the image tag of the primary container, or an empty string if there is none
+func (d Deployment) PrimaryImageTag() string {
+	c := d.PrimaryContainer()
+	if c == nil {
+		return ""
+	}
+	return kube.ImageTag(c.Image)
+}
+
+// VersionDrift returns the version of the deployment and the image tag of its primary container, along with true if
+// both are known and differ, ignoring any leading 'v', e.g. after a partial rollout or a manual patch of the image
+func (d Deployment) VersionDrift() (string, string, bool) {
+	labelVersion := d.Version()
+	imageTag := d.PrimaryImageTag()
+	if labelVersion == "" || imageTag == "" {
+		return labelVersion, imageTag, false
+	}
+	return labelVersion, imageTag, strings.TrimPrefix(labelVersion, "v") != strings.TrimPrefix(imageTag, "v")
+}
+
+// podIssueReasons the waiting reasons of a container which indicate that a pod cannot become ready without
+// intervention
+var podIssueReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+}
+
+// PodIssues returns human readable descriptions of the containers of the deployment's pods which are stuck waiting,
+// for example because their image cannot be pulled or they keep crashing
+func (d Deployment) PodIssues(kc kubernetes.Interface) []string {
+	selector, err := metav1.LabelSelectorAsSelector(d.Deployment.Spec.Selector)
+	if err != nil {
+		log.Logger().Debugf("invalid selector on deployment %s: %s", d.Deployment.Name, err)
+		return nil
+	}
+	pods, err := kc.CoreV1().Pods(d.Deployment.Namespace).List(metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		log.Logger().Debugf("failed to list the pods of deployment %s: %s", d.Deployment.Name, err)
+		return nil
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].Name < pods.Items[j].Name
+	})
+
+	var issues []string
+	for _, pod := range pods.Items {
+		for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+			for _, status := range statuses {
+				waiting := status.State.Waiting
+				if waiting == nil || !podIssueReasons[waiting.Reason] {
+					continue
+				}
+				issue := fmt.Sprintf("pod %s container %s is in %s", pod.Name, status.Name, waiting.Reason)
+				if waiting.Message != "" {
+					issue += ": " + waiting.Message
+				}
+				issues = append(issues, issue)
+			}
+		}
+	}
+	return issues
+}
+
+// URL returns a deployment URL using a scheme matching the service protocol. The URL resolved by List.ResolveURLs
+// is used if there is one, otherwise the service is looked up
 func (d Deployment) URL(kc kubernetes.Interface, a Application) string {
-	url, _ := services.FindServiceURL(kc, d.Deployment.Namespace, a.Name())
+	if d.url != nil {
+		return *d.url
+	}
+	url, _ := services.FindServiceProtocolURL(kc, d.Deployment.Namespace, a.Name())
 	return url
 }
 
+// PreviewURL returns the URL of the Ingress exposing the deployment in a preview environment, falling back to the
+// URL of its Service if there is no such Ingress
+func (d Deployment) PreviewURL(kc kubernetes.Interface, a Application) string {
+	ingresses, err := kc.ExtensionsV1beta1().Ingresses(d.Deployment.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		log.Logger().Debugf("failed to list the ingresses in namespace %s: %s", d.Deployment.Namespace, err)
+		return d.URL(kc, a)
+	}
+	var backendIngress *extensionsv1beta1.Ingress
+	for i := range ingresses.Items {
+		ing := &ingresses.Items[i]
+		if ing.Name == a.Name() {
+			backendIngress = ing
+			break
+		}
+		if backendIngress == nil && ingressHasBackend(ing, a.Name()) {
+			backendIngress = ing
+		}
+	}
+	if url := services.IngressURL(backendIngress); url != "" {
+		return url
+	}
+	return d.URL(kc, a)
+}
+
+// URLInEnvironment returns the URL of the deployment, using the Ingress for preview environments
+func (d Deployment) URLInEnvironment(kc kubernetes.Interface, a Application, e Environment) string {
+	if e.IsPreview() {
+		return d.PreviewURL(kc, a)
+	}
+	return d.URL(kc, a)
+}
+
+// ingressHasBackend returns true if the Ingress routes any traffic to the named Service
+func ingressHasBackend(ing *extensionsv1beta1.Ingress, serviceName string) bool {
+	if ing.Spec.Backend != nil && ing.Spec.Backend.ServiceName == serviceName {
+		return true
+	}
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.ServiceName == serviceName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Options controls how applications and their deployments are fetched
+type Options struct {
+	// IncludeDevEnvironment also matches deployments in the development environment namespace
+	IncludeDevEnvironment bool
+	// Git the git client used to clone the repositories of remote environments, defaults to the git CLI
+	Git gits.Gitter
+	// IncludePreview also matches deployments in preview environments, whose pull request is available via
+	// Environment.PullRequestNumber and Environment.PullRequestAuthor
+	IncludePreview bool
+	// IncludeEnvironments if specified only the environments with these names are considered
+	IncludeEnvironments []string
+	// ExcludeEnvironments the names of environments which are not considered
+	ExcludeEnvironments []string
+	// RequirementsCache if specified reuses the clones of the repositories of remote environments which share the
+	// same repository rather than cloning it for each environment
+	RequirementsCache *RequirementsCache
+	// RetryInterval the delay before retrying to fetch the deployments of an environment after a transient error,
+	// doubled on each attempt. Defaults to one second
+	RetryInterval time.Duration
+	// AppNameResolver derives the application name of a deployment, defaults to DefaultAppNameResolver
+	AppNameResolver AppNameResolver
+	// RequirementsResolver resolves the requirements of remote environments, defaults to loading them from the team
+	// settings or git repository of the environment using Git and the RequirementsCache
+	RequirementsResolver RequirementsResolver
+}
+
+// AppNameResolver derives the name of the application of a deployment in an environment from the labels of the
+// deployment selector
+type AppNameResolver func(labels map[string]string, env *v1.Environment) string
+
+// DefaultAppNameResolver derives the application name from the app label, removing any prefix added for the
+// environment
+func DefaultAppNameResolver(labels map[string]string, env *v1.Environment) string {
+	return kube.GetAppName(labels["app"], env.Spec.Namespace)
+}
+
+// validateEnvironmentFilters returns an error if an environment is both included and excluded
+func (o Options) validateEnvironmentFilters() error {
+	for _, name := range o.ExcludeEnvironments {
+		if util.StringArrayIndex(o.IncludeEnvironments, name) >= 0 {
+			return fmt.Errorf("the environment %s cannot be both included and excluded", name)
+		}
+	}
+	return nil
+}
+
+// includesEnvironment returns true if the environment with the given name should be considered
+func (o Options) includesEnvironment(name string) bool {
+	if len(o.IncludeEnvironments) > 0 && util.StringArrayIndex(o.IncludeEnvironments, name) < 0 {
+		return false
+	}
+	return util.StringArrayIndex(o.ExcludeEnvironments, name) < 0
+}
+
+// sourceRepositoryPageSize the maximum number of SourceRepositories fetched in a single request
+const sourceRepositoryPageSize = 500
+
+// ErrStopWalk can be returned by a WalkApplications callback to stop walking the applications without failing
+var ErrStopWalk = errors.New("stop walking applications")
+
 // GetApplications fetches all Applications
 func GetApplications(factory clients.Factory) (List, error) {
+	return ListApplications(factory, Options{})
+}
+
+// ListApplications fetches all Applications using the given options, sorted by name
+func ListApplications(factory clients.Factory, options Options) (List, error) {
 	list := List{
 		Items: make([]Application, 0),
 	}
+	err := WalkApplications(factory, options, func(app Application) error {
+		list.Items = append(list.Items, app)
+		return nil
+	})
+	if err != nil {
+		return list, err
+	}
+	// the SourceRepositories are not returned in a stable order so lets sort the applications by name
+	sort.SliceStable(list.Items, func(i, j int) bool {
+		return list.Items[i].Name() < list.Items[j].Name()
+	})
+	list.DevRequirements = loadDevRequirements(factory)
+	return list, nil
+}
 
+// loadDevRequirements loads the requirements from the team settings of the development environment, returning nil if
+// there are none or they cannot be loaded
+func loadDevRequirements(factory clients.Factory) *config.RequirementsConfig {
 	client, namespace, err := factory.CreateJXClient()
 	if err != nil {
-		return list, errors.Wrap(err, "failed to create a jx client from applications.GetApplications")
+		log.Logger().Debugf("failed to create a jx client to load the requirements of the development environment: %s", err)
+		return nil
+	}
+	devEnv, err := kube.GetDevEnvironment(client, namespace)
+	if err != nil || devEnv == nil {
+		log.Logger().Debugf("failed to find the development environment in namespace %s: %v", namespace, err)
+		return nil
+	}
+	requirements, err := config.GetRequirementsConfigFromTeamSettings(&devEnv.Spec.TeamSettings)
+	if err != nil {
+		log.Logger().Debugf("failed to load the requirements of the development environment: %s", err)
+		return nil
 	}
+	return requirements
+}
 
-	// fetch ALL repositories
-	srList, err := client.JenkinsV1().SourceRepositories(namespace).List(metav1.ListOptions{})
+// WalkApplications invokes the callback for each Application, with its environments resolved, without building the
+// complete List in memory. The SourceRepositories are fetched a page at a time, each page being walked before the
+// next one is fetched. Returning ErrStopWalk from the callback stops walking without an error
+func WalkApplications(factory clients.Factory, options Options, fn func(Application) error) error {
+	err := options.validateEnvironmentFilters()
 	if err != nil {
-		return list, errors.Wrapf(err, "failed to find any SourceRepositories in namespace %s", namespace)
+		return err
+	}
+
+	client, namespace, err := factory.CreateJXClient()
+	if err != nil {
+		return errors.Wrap(err, "failed to create a jx client from applications.GetApplications")
 	}
 
 	// fetch all environments
 	envMap, _, err := kube.GetOrderedEnvironments(client, namespace)
 	if err != nil {
-		return list, errors.Wrapf(err, "failed to fetch environments in namespace %s", namespace)
+		return errors.Wrapf(err, "failed to fetch environments in namespace %s", namespace)
 	}
 
-	// only keep permanent environments
-	permanentEnvsMap := map[string]*v1.Environment{}
+	// only keep permanent environments, and previews if requested, the filtered out environments are still used to
+	// skip environment repositories
+	envsMap := map[string]*v1.Environment{}
+	allPermanentEnvsMap := map[string]*v1.Environment{}
 	for _, env := range envMap {
 		if env.Spec.Kind.IsPermanent() {
-			permanentEnvsMap[env.Spec.Namespace] = env
+			allPermanentEnvsMap[env.Spec.Namespace] = env
+			if options.includesEnvironment(env.Name) {
+				envsMap[env.Spec.Namespace] = env
+			}
+		} else if options.IncludePreview && env.Spec.Kind == v1.EnvironmentKindTypePreview {
+			envsMap[env.Spec.Namespace] = env
 		}
 	}
 
-	// copy repositories that aren't environments to our applications list
-	for _, sr := range srList.Items {
-		if !kube.IsIncludedInTheGivenEnvs(permanentEnvsMap, &sr) {
+	kubeClient, _, err := factory.CreateKubeClient()
+	if err != nil {
+		return errors.Wrap(err, "failed to create a kube client from applications.GetApplications")
+	}
+
+	// fetch deployments by environment (excluding dev unless requested)
+	deployments, err := getEnvironmentDeployments(kubeClient, envsMap, options)
+	if err != nil {
+		return err
+	}
+
+	// walk the repositories that aren't environments a page at a time
+	err = walkSourceRepositories(client, namespace, func(srs []v1.SourceRepository) error {
+		for _, sr := range srs {
+			if kube.IsIncludedInTheGivenEnvs(allPermanentEnvsMap, &sr) {
+				continue
+			}
 			srCopy := sr
-			list.Items = append(list.Items, Application{&srCopy, make(map[string]Environment)})
+			app := Application{&srCopy, make(map[string]Environment)}
+			err := app.appendMatchingDeployments(envsMap, deployments, options.AppNameResolver)
+			if err != nil {
+				return err
+			}
+			err = fn(app)
+			if err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+	if err == ErrStopWalk {
+		return nil
 	}
+	return err
+}
 
-	kubeClient, _, err := factory.CreateKubeClient()
+// listSourceRepositories lists all the SourceRepositories in the namespace a page at a time
+func listSourceRepositories(client versioned.Interface, namespace string) ([]v1.SourceRepository, error) {
+	answer := []v1.SourceRepository{}
+	err := walkSourceRepositories(client, namespace, func(srs []v1.SourceRepository) error {
+		answer = append(answer, srs...)
+		return nil
+	})
+	return answer, err
+}
 
-	// fetch deployments by environment (excluding dev)
+// walkSourceRepositories invokes the callback for each page of the SourceRepositories in the namespace, only fetching
+// the next page once the callback has returned. An error returned by the callback stops the walk and is returned
+func walkSourceRepositories(client versioned.Interface, namespace string, fn func([]v1.SourceRepository) error) error {
+	options := metav1.ListOptions{
+		Limit: sourceRepositoryPageSize,
+	}
+	for {
+		srList, err := client.JenkinsV1().SourceRepositories(namespace).List(options)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find any SourceRepositories in namespace %s", namespace)
+		}
+		err = fn(srList.Items)
+		if err != nil {
+			return err
+		}
+		if srList.Continue == "" {
+			return nil
+		}
+		options.Continue = srList.Continue
+	}
+}
+
+// getEnvironmentDeployments fetches the deployments of each environment concurrently, excluding any environment whose
+// namespace is being deleted and dev unless included by the options, indexed by the environment namespace. The
+// deployments of remote environments are fetched from their own clusters
+func getEnvironmentDeployments(kubeClient kubernetes.Interface, envs map[string]*v1.Environment, options Options) (map[string]map[string]appsv1.Deployment, error) {
+	if options.Git == nil {
+		options.Git = gits.NewGitCLI()
+	}
+
+	// environments sharing requirements only load them once
+	memo := NewRequirementsMemo()
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	var fetchErr error
 	deployments := make(map[string]map[string]appsv1.Deployment)
-	for _, env := range permanentEnvsMap {
-		if env.Spec.Kind != v1.EnvironmentKindTypeDevelopment {
-			envDeployments, err := kube.GetDeployments(kubeClient, env.Spec.Namespace)
+	for _, env := range envs {
+		if env.Spec.Kind == v1.EnvironmentKindTypeDevelopment && !options.IncludeDevEnvironment {
+			continue
+		}
+		wg.Add(1)
+		go func(env *v1.Environment) {
+			defer wg.Done()
+			envDeployments, err := getDeploymentsOfEnvironment(kubeClient, env, options, memo)
+
+			lock.Lock()
+			defer lock.Unlock()
 			if err != nil {
-				return list, err
+				if fetchErr == nil {
+					fetchErr = err
+				}
+				return
+			}
+			if envDeployments != nil {
+				deployments[env.Spec.Namespace] = envDeployments
 			}
+		}(env)
+	}
+	wg.Wait()
+	return deployments, fetchErr
+}
 
-			deployments[env.Spec.Namespace] = envDeployments
+// getDeploymentsOfEnvironment fetches the deployments of a single environment, returning nil if the environment
+// should be skipped
+func getDeploymentsOfEnvironment(kubeClient kubernetes.Interface, env *v1.Environment, options Options, memo *RequirementsMemo) (map[string]appsv1.Deployment, error) {
+	if env.Spec.RemoteCluster {
+		resolver := options.RequirementsResolver
+		if resolver == nil {
+			resolver = NewRequirementsResolver(options.Git, options.RequirementsCache)
+		}
+		envDeployments, err := getRemoteDeployments(resolver, memo, env)
+		if err != nil {
+			log.Logger().Warnf("unable to fetch the deployments of remote environment %s: %s", env.Name, err)
+			return nil, nil
 		}
+		return envDeployments, nil
 	}
+	if isNamespaceTerminating(kubeClient, env.Spec.Namespace) {
+		log.Logger().Debugf("skipping environment %s as its namespace %s is being deleted", env.Name, env.Spec.Namespace)
+		return nil, nil
+	}
+	return getDeploymentsWithRetry(kubeClient, env.Spec.Namespace, options.RetryInterval)
+}
 
-	err = list.appendMatchingDeployments(permanentEnvsMap, deployments)
-	if err != nil {
-		return list, err
+// deploymentFetchAttempts the maximum number of attempts to fetch the deployments of an environment
+const deploymentFetchAttempts = 3
+
+// getDeploymentsWithRetry fetches the deployments in the namespace, retrying with an exponential backoff on transient
+// errors such as timeouts or throttling. Other errors, e.g. when the namespace is forbidden, fail immediately
+func getDeploymentsWithRetry(kubeClient kubernetes.Interface, ns string, interval time.Duration) (map[string]appsv1.Deployment, error) {
+	if interval <= 0 {
+		interval = time.Second
 	}
+	for attempt := 1; ; attempt++ {
+		deployments, err := kube.GetDeployments(kubeClient, ns)
+		if err == nil || !isRetryableError(err) {
+			return deployments, err
+		}
+		if attempt >= deploymentFetchAttempts {
+			return nil, errors.Wrapf(err, "fetching the deployments in namespace %s after %d attempts", ns, attempt)
+		}
+		log.Logger().Debugf("failed to fetch the deployments in namespace %s, retrying in %s: %s", ns, interval, err)
+		time.Sleep(interval)
+		interval *= 2
+	}
+}
 
-	return list, nil
+// isRetryableError returns true if the error returned by the API server is likely to be transient
+func isRetryableError(err error) bool {
+	return apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) || apierrors.IsTooManyRequests(err) ||
+		apierrors.IsInternalError(err) || apierrors.IsServiceUnavailable(err) || apierrors.IsUnexpectedServerError(err)
 }
 
-func getDeploymentAppNameInEnvironment(d appsv1.Deployment, e *v1.Environment) (string, error) {
+// isNamespaceTerminating returns true if the namespace has been marked for deletion
+func isNamespaceTerminating(kubeClient kubernetes.Interface, ns string) bool {
+	namespace, err := kubeClient.CoreV1().Namespaces().Get(ns, metav1.GetOptions{})
+	if err != nil {
+		// we may not be allowed to view namespaces so lets carry on and look for deployments
+		return false
+	}
+	return namespace.DeletionTimestamp != nil
+}
+
+func getDeploymentAppNameInEnvironment(d appsv1.Deployment, e *v1.Environment, resolver AppNameResolver) (string, error) {
 	labels, err := metav1.LabelSelectorAsMap(d.Spec.Selector)
 	if err != nil {
 		return "", err
 	}
-
-	name := kube.GetAppName(labels["app"], e.Spec.Namespace)
-	return name, nil
+	if resolver == nil {
+		resolver = DefaultAppNameResolver
+	}
+	return resolver(labels, e), nil
 }
 
-func (l List) appendMatchingDeployments(envs map[string]*v1.Environment, deps map[string]map[string]appsv1.Deployment) error {
+func (l List) appendMatchingDeployments(envs map[string]*v1.Environment, deps map[string]map[string]appsv1.Deployment, resolver AppNameResolver) error {
 	for _, app := range l.Items {
-		for envName, env := range envs {
-			for _, dep := range deps[envName] {
-				depAppName, err := getDeploymentAppNameInEnvironment(dep, env)
-				if err != nil {
-					return errors.Wrap(err, "getting app name")
-				}
-				if depAppName == app.Name() && !flagger.IsCanaryAuxiliaryDeployment(dep) {
-					depCopy := dep
-					app.Environments[env.Name] = Environment{
-						*env,
-						[]Deployment{{&depCopy}},
-					}
+		err := app.appendMatchingDeployments(envs, deps, resolver)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// appendMatchingDeployments adds the environments containing a deployment of the application. The environments are
+// matched into a local map which is only merged into the application once all of them have been matched. The
+// application name of each deployment is derived by the resolver, defaulting to DefaultAppNameResolver
+func (a Application) appendMatchingDeployments(envs map[string]*v1.Environment, deps map[string]map[string]appsv1.Deployment, resolver AppNameResolver) error {
+	environments := map[string]Environment{}
+	for envName, env := range envs {
+		for _, dep := range deps[envName] {
+			depAppName, err := getDeploymentAppNameInEnvironment(dep, env, resolver)
+			if err != nil {
+				return errors.Wrap(err, "getting app name")
+			}
+			if depAppName == a.Name() && !flagger.IsCanaryAuxiliaryDeployment(dep) {
+				depCopy := dep
+				environments[env.Name] = Environment{
+					*env,
+					[]Deployment{{Deployment: &depCopy}},
 				}
 			}
 		}
 	}
 
+	for name, env := range environments {
+		a.Environments[name] = env
+	}
 	return nil
 }
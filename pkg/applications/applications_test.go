@@ -3,12 +3,29 @@
 package applications
 
 import (
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	v1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	jxfake "github.com/jenkins-x/jx/pkg/client/clientset/versioned/fake"
+	clientmocks "github.com/jenkins-x/jx/pkg/cmd/clients/mocks"
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/kube"
+	"github.com/jenkins-x/jx/pkg/kube/services"
+	. "github.com/petergtz/pegomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestAppendMatchingDeployments(t *testing.T) {
@@ -31,7 +48,7 @@ func TestAppendMatchingDeployments(t *testing.T) {
 		{
 			"Source repository doesn't have a matching deployment",
 			List{
-				[]Application{
+				Items: []Application{
 					{
 						&v1.SourceRepository{
 							Spec: v1.SourceRepositorySpec{
@@ -49,7 +66,7 @@ func TestAppendMatchingDeployments(t *testing.T) {
 		{
 			"Source repository matches a single deployment",
 			List{
-				[]Application{
+				Items: []Application{
 					{
 						&v1.SourceRepository{
 							Spec: v1.SourceRepositorySpec{
@@ -86,7 +103,7 @@ func TestAppendMatchingDeployments(t *testing.T) {
 		{
 			"Source repository matches multiple deployments",
 			List{
-				[]Application{
+				Items: []Application{
 					{
 						&v1.SourceRepository{
 							Spec: v1.SourceRepositorySpec{
@@ -146,7 +163,7 @@ func TestAppendMatchingDeployments(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		err := test.list.appendMatchingDeployments(test.environments, test.deployments)
+		err := test.list.appendMatchingDeployments(test.environments, test.deployments, nil)
 
 		assert.NoError(t, err, test.name)
 		assert.Equal(t, test.wantApplications, len(test.list.Items), test.name)
@@ -159,3 +176,1249 @@ func TestAppendMatchingDeployments(t *testing.T) {
 		}
 	}
 }
+
+func TestAppendMatchingDeploymentsWithAppNameResolver(t *testing.T) {
+	envs := map[string]*v1.Environment{
+		"jx-staging": {
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "staging",
+			},
+			Spec: v1.EnvironmentSpec{
+				Namespace: "jx-staging",
+			},
+		},
+	}
+	deployments := map[string]map[string]appsv1.Deployment{
+		"jx-staging": {
+			"team-a-my-repo-name": {
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "team-a-my-repo-name",
+					Namespace: "jx-staging",
+				},
+				Spec: appsv1.DeploymentSpec{
+					Selector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{
+							"app":  "team-a-my-repo-name",
+							"team": "team-a",
+						},
+					},
+				},
+			},
+		},
+	}
+	newList := func() List {
+		return List{
+			Items: []Application{
+				{
+					&v1.SourceRepository{Spec: v1.SourceRepositorySpec{Repo: "my-repo-name"}},
+					make(map[string]Environment),
+				},
+			},
+		}
+	}
+
+	// the team prefix is not removed by default
+	list := newList()
+	err := list.appendMatchingDeployments(envs, deployments, nil)
+	require.NoError(t, err)
+	assert.Empty(t, list.Items[0].Environments)
+
+	stripTeamPrefix := func(labels map[string]string, env *v1.Environment) string {
+		return strings.TrimPrefix(DefaultAppNameResolver(labels, env), labels["team"]+"-")
+	}
+	list = newList()
+	err = list.appendMatchingDeployments(envs, deployments, stripTeamPrefix)
+	require.NoError(t, err)
+	require.Contains(t, list.Items[0].Environments, "staging")
+	assert.Len(t, list.Items[0].Environments["staging"].Deployments, 1)
+}
+
+func TestGetEnvironmentDeploymentsSkipsTerminatingNamespaces(t *testing.T) {
+	deletionTime := metav1.Now()
+	kubeClient := fake.NewSimpleClientset(
+		&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "jx-staging",
+			},
+		},
+		&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "jx-production",
+				DeletionTimestamp: &deletionTime,
+			},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "jx-my-repo-name",
+				Namespace: "jx-staging",
+			},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "jx-my-repo-name",
+				Namespace: "jx-production",
+			},
+		},
+	)
+
+	envs := map[string]*v1.Environment{
+		"jx-staging": {
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "staging",
+			},
+			Spec: v1.EnvironmentSpec{
+				Namespace: "jx-staging",
+				Kind:      v1.EnvironmentKindTypePermanent,
+			},
+		},
+		"jx-production": {
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "production",
+			},
+			Spec: v1.EnvironmentSpec{
+				Namespace: "jx-production",
+				Kind:      v1.EnvironmentKindTypePermanent,
+			},
+		},
+	}
+
+	deployments, err := getEnvironmentDeployments(kubeClient, envs, Options{})
+	require.NoError(t, err)
+
+	assert.Len(t, deployments, 1)
+	assert.Len(t, deployments["jx-staging"], 1)
+	assert.NotContains(t, deployments, "jx-production")
+}
+
+func TestGetEnvironmentDeploymentsRetriesTransientErrors(t *testing.T) {
+	env := &v1.Environment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "staging",
+		},
+		Spec: v1.EnvironmentSpec{
+			Namespace: "jx-staging",
+			Kind:      v1.EnvironmentKindTypePermanent,
+		},
+	}
+	envs := map[string]*v1.Environment{"jx-staging": env}
+	options := Options{RetryInterval: time.Millisecond}
+
+	// the first two attempts fail with transient errors
+	kubeClient := fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "jx-my-repo-name",
+				Namespace: "jx-staging",
+			},
+		},
+	)
+	calls := 0
+	kubeClient.PrependReactor("list", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		calls++
+		switch calls {
+		case 1:
+			return true, nil, apierrors.NewServerTimeout(appsv1.Resource("deployments"), "list", 1)
+		case 2:
+			return true, nil, apierrors.NewTooManyRequests("throttled", 1)
+		}
+		return false, nil, nil
+	})
+
+	deployments, err := getEnvironmentDeployments(kubeClient, envs, options)
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Len(t, deployments["jx-staging"], 1)
+
+	// forbidden errors are not retried
+	kubeClient = fake.NewSimpleClientset()
+	calls = 0
+	kubeClient.PrependReactor("list", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		calls++
+		return true, nil, apierrors.NewForbidden(appsv1.Resource("deployments"), "", fmt.Errorf("no access"))
+	})
+
+	_, err = getEnvironmentDeployments(kubeClient, envs, options)
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestGetEnvironmentDeploymentsIncludeDevEnvironment(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "my-repo-name",
+				Namespace: "jx",
+			},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{
+						"app": "my-repo-name",
+					},
+				},
+			},
+		},
+	)
+
+	envs := map[string]*v1.Environment{
+		"jx": {
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "dev",
+			},
+			Spec: v1.EnvironmentSpec{
+				Namespace: "jx",
+				Kind:      v1.EnvironmentKindTypeDevelopment,
+			},
+		},
+	}
+
+	for _, includeDev := range []bool{false, true} {
+		list := List{
+			Items: []Application{
+				{
+					&v1.SourceRepository{
+						Spec: v1.SourceRepositorySpec{
+							Repo: "my-repo-name",
+						},
+					},
+					make(map[string]Environment),
+				},
+			},
+		}
+
+		deployments, err := getEnvironmentDeployments(kubeClient, envs, Options{IncludeDevEnvironment: includeDev})
+		require.NoError(t, err)
+
+		err = list.appendMatchingDeployments(envs, deployments, nil)
+		require.NoError(t, err)
+
+		if includeDev {
+			assert.Len(t, list.Items[0].Environments["dev"].Deployments, 1, "dev deployment should be included")
+		} else {
+			assert.Empty(t, list.Items[0].Environments, "dev deployment should be excluded by default")
+		}
+	}
+}
+
+func TestWalkApplicationsStopsEarly(t *testing.T) {
+	RegisterMockTestingT(t)
+
+	jxObjects := []runtime.Object{}
+	for i := 0; i < 5; i++ {
+		jxObjects = append(jxObjects, &v1.SourceRepository{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("repo-%d", i),
+				Namespace: "jx",
+			},
+			Spec: v1.SourceRepositorySpec{
+				Org:  "my-org",
+				Repo: fmt.Sprintf("repo-%d", i),
+			},
+		})
+	}
+
+	factory := clientmocks.NewMockFactory()
+	When(factory.CreateJXClient()).ThenReturn(jxfake.NewSimpleClientset(jxObjects...), "jx", nil)
+	When(factory.CreateKubeClient()).ThenReturn(fake.NewSimpleClientset(), "jx", nil)
+
+	walked := 0
+	err := WalkApplications(factory, Options{}, func(app Application) error {
+		walked++
+		if walked == 2 {
+			return ErrStopWalk
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, walked)
+
+	list, err := ListApplications(factory, Options{})
+	require.NoError(t, err)
+	assert.Len(t, list.Items, 5)
+}
+
+func TestWalkApplicationsFetchesPagesLazily(t *testing.T) {
+	RegisterMockTestingT(t)
+
+	page := func(cont string, names ...string) *v1.SourceRepositoryList {
+		list := &v1.SourceRepositoryList{}
+		list.Continue = cont
+		for _, name := range names {
+			list.Items = append(list.Items, v1.SourceRepository{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: "jx",
+				},
+				Spec: v1.SourceRepositorySpec{
+					Org:  "my-org",
+					Repo: name,
+				},
+			})
+		}
+		return list
+	}
+	pages := []*v1.SourceRepositoryList{
+		page("page-2", "repo-1", "repo-2"),
+		page("", "repo-3"),
+	}
+
+	client := jxfake.NewSimpleClientset()
+	calls := 0
+	client.PrependReactor("list", "sourcerepositories", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		answer := pages[calls]
+		calls++
+		return true, answer, nil
+	})
+
+	factory := clientmocks.NewMockFactory()
+	When(factory.CreateJXClient()).ThenReturn(client, "jx", nil)
+	When(factory.CreateKubeClient()).ThenReturn(fake.NewSimpleClientset(), "jx", nil)
+
+	// the applications of the first page are walked before the next page is fetched
+	names := []string{}
+	err := WalkApplications(factory, Options{}, func(app Application) error {
+		assert.Equal(t, 1, calls, "only the first page should have been fetched")
+		names = append(names, app.Name())
+		if len(names) == 2 {
+			return ErrStopWalk
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"repo-1", "repo-2"}, names)
+	assert.Equal(t, 1, calls, "the next page should not be fetched once the walk is stopped")
+}
+
+func TestGetApplicationsWithManyEnvironments(t *testing.T) {
+	RegisterMockTestingT(t)
+
+	const envCount = 20
+	jxObjects := []runtime.Object{
+		&v1.SourceRepository{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "my-app",
+				Namespace: "jx",
+			},
+			Spec: v1.SourceRepositorySpec{
+				Org:  "my-org",
+				Repo: "my-app",
+			},
+		},
+	}
+	kubeObjects := []runtime.Object{}
+	for i := 0; i < envCount; i++ {
+		ns := fmt.Sprintf("jx-env-%d", i)
+		jxObjects = append(jxObjects, &v1.Environment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("env-%d", i),
+				Namespace: "jx",
+			},
+			Spec: v1.EnvironmentSpec{
+				Namespace: ns,
+				Kind:      v1.EnvironmentKindTypePermanent,
+			},
+		})
+		kubeObjects = append(kubeObjects, &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "my-app",
+				Namespace: ns,
+			},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{
+						"app": "my-app",
+					},
+				},
+			},
+		})
+	}
+
+	factory := clientmocks.NewMockFactory()
+	When(factory.CreateJXClient()).ThenReturn(jxfake.NewSimpleClientset(jxObjects...), "jx", nil)
+	When(factory.CreateKubeClient()).ThenReturn(fake.NewSimpleClientset(kubeObjects...), "jx", nil)
+
+	list, err := GetApplications(factory)
+	require.NoError(t, err)
+
+	require.Len(t, list.Items, 1)
+	assert.Len(t, list.Items[0].Environments, envCount)
+	for i := 0; i < envCount; i++ {
+		assert.Len(t, list.Items[0].Environments[fmt.Sprintf("env-%d", i)].Deployments, 1)
+	}
+}
+
+func TestGetApplicationsSortedByName(t *testing.T) {
+	RegisterMockTestingT(t)
+
+	sourceRepository := func(name, repo string) *v1.SourceRepository {
+		return &v1.SourceRepository{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "jx",
+			},
+			Spec: v1.SourceRepositorySpec{
+				Org:  "my-org",
+				Repo: repo,
+			},
+		}
+	}
+	jxObjects := []runtime.Object{
+		sourceRepository("my-org-a", "zebra"),
+		sourceRepository("my-org-b", "apple"),
+		sourceRepository("my-org-c", "mango"),
+	}
+
+	factory := clientmocks.NewMockFactory()
+	When(factory.CreateJXClient()).ThenReturn(jxfake.NewSimpleClientset(jxObjects...), "jx", nil)
+	When(factory.CreateKubeClient()).ThenReturn(fake.NewSimpleClientset(), "jx", nil)
+
+	list, err := GetApplications(factory)
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, app := range list.Items {
+		names = append(names, app.Name())
+	}
+	assert.Equal(t, []string{"apple", "mango", "zebra"}, names)
+}
+
+func TestListApplicationsFiltersEnvironments(t *testing.T) {
+	RegisterMockTestingT(t)
+
+	jxObjects := []runtime.Object{
+		&v1.SourceRepository{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "my-app",
+				Namespace: "jx",
+			},
+			Spec: v1.SourceRepositorySpec{
+				Org:  "my-org",
+				Repo: "my-app",
+			},
+		},
+	}
+	kubeObjects := []runtime.Object{}
+	for _, name := range []string{"staging", "production", "ephemeral"} {
+		ns := "jx-" + name
+		jxObjects = append(jxObjects, &v1.Environment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "jx",
+			},
+			Spec: v1.EnvironmentSpec{
+				Namespace: ns,
+				Kind:      v1.EnvironmentKindTypePermanent,
+			},
+		})
+		kubeObjects = append(kubeObjects, &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "my-app",
+				Namespace: ns,
+			},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{
+						"app": "my-app",
+					},
+				},
+			},
+		})
+	}
+
+	factory := clientmocks.NewMockFactory()
+	When(factory.CreateJXClient()).ThenReturn(jxfake.NewSimpleClientset(jxObjects...), "jx", nil)
+	When(factory.CreateKubeClient()).ThenReturn(fake.NewSimpleClientset(kubeObjects...), "jx", nil)
+
+	environmentNames := func(list List) []string {
+		names := []string{}
+		for _, a := range list.Items {
+			for name := range a.Environments {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+
+	list, err := ListApplications(factory, Options{IncludeEnvironments: []string{"staging"}})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	assert.ElementsMatch(t, []string{"staging"}, environmentNames(list))
+
+	list, err = ListApplications(factory, Options{ExcludeEnvironments: []string{"ephemeral"}})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	assert.ElementsMatch(t, []string{"staging", "production"}, environmentNames(list))
+
+	_, err = ListApplications(factory, Options{
+		IncludeEnvironments: []string{"staging", "ephemeral"},
+		ExcludeEnvironments: []string{"ephemeral"},
+	})
+	assert.Error(t, err)
+}
+
+func TestListApplicationsIncludePreview(t *testing.T) {
+	RegisterMockTestingT(t)
+
+	preview := &v1.Environment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-org-my-app-pr-1",
+			Namespace: "jx",
+		},
+		Spec: v1.EnvironmentSpec{
+			Namespace:      "jx-my-org-my-app-pr-1",
+			Kind:           v1.EnvironmentKindTypePreview,
+			PullRequestURL: "https://github.com/my-org/my-app/pull/1",
+			Source: v1.EnvironmentRepository{
+				URL: "https://github.com/my-org/my-app.git",
+				Ref: "my-feature",
+			},
+			PreviewGitSpec: v1.PreviewGitSpec{
+				Name: "1",
+				URL:  "https://github.com/my-org/my-app/pull/1",
+				User: v1.UserSpec{
+					Username: "octocat",
+				},
+			},
+		},
+	}
+	jxObjects := []runtime.Object{
+		&v1.SourceRepository{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "my-app",
+				Namespace: "jx",
+			},
+			Spec: v1.SourceRepositorySpec{
+				Org:  "my-org",
+				Repo: "my-app",
+			},
+		},
+		preview,
+	}
+	kubeObjects := []runtime.Object{
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "my-app",
+				Namespace: "jx-my-org-my-app-pr-1",
+			},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{
+						"app": "my-app",
+					},
+				},
+			},
+		},
+	}
+
+	factory := clientmocks.NewMockFactory()
+	When(factory.CreateJXClient()).ThenReturn(jxfake.NewSimpleClientset(jxObjects...), "jx", nil)
+	When(factory.CreateKubeClient()).ThenReturn(fake.NewSimpleClientset(kubeObjects...), "jx", nil)
+
+	list, err := ListApplications(factory, Options{})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	assert.Empty(t, list.Items[0].Environments, "previews should be excluded by default")
+
+	list, err = ListApplications(factory, Options{IncludePreview: true})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	require.Contains(t, list.Items[0].Environments, preview.Name)
+	env := list.Items[0].Environments[preview.Name]
+	assert.Equal(t, "1", env.PullRequestNumber())
+	assert.Equal(t, "octocat", env.PullRequestAuthor())
+	assert.Equal(t, "my-feature", env.PullRequestBranch())
+
+	// the number falls back to the pull request URL
+	env.Environment.Spec.PreviewGitSpec.Name = ""
+	assert.Equal(t, "1", env.PullRequestNumber())
+
+	// permanent environments have no pull request
+	env.Environment.Spec.Kind = v1.EnvironmentKindTypePermanent
+	assert.Equal(t, "", env.PullRequestNumber())
+	assert.Equal(t, "", env.PullRequestAuthor())
+}
+
+func TestApplicationDeployedEnvironmentCount(t *testing.T) {
+	deployment := Deployment{Deployment: &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "jx-my-app"}}}
+	app := Application{
+		&v1.SourceRepository{Spec: v1.SourceRepositorySpec{Repo: "my-app"}},
+		map[string]Environment{
+			"dev":        {Environment: *kube.NewPermanentEnvironment("dev")},
+			"staging":    {Environment: *kube.NewPermanentEnvironment("staging"), Deployments: []Deployment{deployment}},
+			"production": {Environment: *kube.NewPermanentEnvironment("production"), Deployments: []Deployment{deployment}},
+		},
+	}
+	assert.Equal(t, 2, app.DeployedEnvironmentCount())
+
+	empty := Application{&v1.SourceRepository{}, map[string]Environment{}}
+	assert.Equal(t, 0, empty.DeployedEnvironmentCount())
+}
+
+func TestApplicationMissingEnvironments(t *testing.T) {
+	deployment := Deployment{Deployment: &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "jx-my-app"}}}
+	app := Application{
+		&v1.SourceRepository{Spec: v1.SourceRepositorySpec{Repo: "my-app"}},
+		map[string]Environment{
+			"staging":    {Environment: *kube.NewPermanentEnvironment("staging"), Deployments: []Deployment{deployment}},
+			"production": {Environment: *kube.NewPermanentEnvironment("production")},
+		},
+	}
+	assert.Equal(t, []string{"production"}, app.MissingEnvironments([]string{"staging", "production"}))
+	assert.Equal(t, []string{"production", "qa"}, app.MissingEnvironments([]string{"production", "qa"}))
+	assert.Empty(t, app.MissingEnvironments([]string{"staging"}))
+}
+
+func TestEnvironmentGitSourceURL(t *testing.T) {
+	gitURL := "https://github.com/my-org/environment-staging.git"
+	list := List{
+		Items: []Application{
+			{
+				&v1.SourceRepository{Spec: v1.SourceRepositorySpec{Repo: "my-app"}},
+				map[string]Environment{
+					"staging":    {Environment: *kube.NewPermanentEnvironmentWithGit("staging", gitURL)},
+					"production": {Environment: *kube.NewPermanentEnvironment("production")},
+				},
+			},
+		},
+	}
+
+	envs := list.Items[0].Environments
+	assert.Equal(t, gitURL, envs["staging"].GitSourceURL())
+	assert.Equal(t, "", envs["production"].GitSourceURL())
+
+	staging := list.Environments()["staging"]
+	assert.Equal(t, gitURL, Environment{Environment: staging}.GitSourceURL())
+}
+
+func TestDeploymentAnnotationAndLabel(t *testing.T) {
+	d := Deployment{Deployment: &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "my-app",
+			Annotations: map[string]string{
+				"example.com/owner": "team-a",
+			},
+			Labels: map[string]string{
+				"example.com/slack-channel": "#team-a",
+			},
+		},
+	}}
+
+	assert.Equal(t, "team-a", d.Annotation("example.com/owner"))
+	assert.Equal(t, "", d.Annotation("example.com/slack-channel"))
+	assert.Equal(t, "#team-a", d.Label("example.com/slack-channel"))
+	assert.Equal(t, "", d.Label("example.com/owner"))
+
+	empty := Deployment{Deployment: &appsv1.Deployment{}}
+	assert.Equal(t, "", empty.Annotation("example.com/owner"))
+	assert.Equal(t, "", empty.Label("example.com/slack-channel"))
+}
+
+func TestDeploymentIsHealthy(t *testing.T) {
+	three := int32(3)
+	zero := int32(0)
+	tests := []struct {
+		name             string
+		replicas         *int32
+		ready            int32
+		minReadyFraction float64
+		want             bool
+	}{
+		{"all ready", &three, 3, 1, true},
+		{"surge", &three, 4, 1, true},
+		{"shortfall within threshold", &three, 2, 0.66, true},
+		{"shortfall below threshold", &three, 2, 0.9, false},
+		{"none ready", &three, 0, 0.5, false},
+		{"nil replicas defaults to 1 ready", nil, 1, 1, true},
+		{"nil replicas defaults to 1 not ready", nil, 0, 0.5, false},
+		{"scaled to zero", &zero, 0, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Deployment{Deployment: &appsv1.Deployment{
+				Spec: appsv1.DeploymentSpec{
+					Replicas: tt.replicas,
+				},
+				Status: appsv1.DeploymentStatus{
+					ReadyReplicas: tt.ready,
+				},
+			}}
+			assert.Equal(t, tt.want, d.IsHealthy(tt.minReadyFraction))
+		})
+	}
+}
+
+func TestDeploymentLastRolloutTime(t *testing.T) {
+	rolledOut := time.Date(2020, time.March, 4, 12, 30, 0, 0, time.UTC)
+	d := Deployment{Deployment: &appsv1.Deployment{
+		Status: appsv1.DeploymentStatus{
+			Conditions: []appsv1.DeploymentCondition{
+				{
+					Type:           appsv1.DeploymentAvailable,
+					Status:         corev1.ConditionTrue,
+					LastUpdateTime: metav1.NewTime(rolledOut.Add(time.Hour)),
+				},
+				{
+					Type:           appsv1.DeploymentProgressing,
+					Status:         corev1.ConditionTrue,
+					Reason:         "NewReplicaSetAvailable",
+					LastUpdateTime: metav1.NewTime(rolledOut),
+				},
+			},
+		},
+	}}
+	assert.True(t, rolledOut.Equal(d.LastRolloutTime()), "LastRolloutTime() = %s", d.LastRolloutTime())
+
+	d = Deployment{Deployment: &appsv1.Deployment{}}
+	assert.True(t, d.LastRolloutTime().IsZero())
+}
+
+func TestDeploymentContainerResources(t *testing.T) {
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("100m"),
+			corev1.ResourceMemory: resource.MustParse("128Mi"),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("500m"),
+			corev1.ResourceMemory: resource.MustParse("512Mi"),
+		},
+	}
+	d := Deployment{Deployment: &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "my-app",
+		},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:      "my-app",
+							Resources: resources,
+						},
+						{
+							Name: "istio-proxy",
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{
+									corev1.ResourceCPU: resource.MustParse("10m"),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}}
+
+	actual := d.ContainerResources()
+	require.Len(t, actual, 1)
+	assert.Equal(t, resources, actual["my-app"])
+	assert.Equal(t, "100m", actual["my-app"].Requests.Cpu().String())
+	assert.Equal(t, "512Mi", actual["my-app"].Limits.Memory().String())
+}
+
+func TestDeploymentPrimaryImageTag(t *testing.T) {
+	containers := []corev1.Container{
+		{Name: "istio-proxy", Image: "docker.io/istio/proxyv2:1.4.0"},
+		{Name: "my-app", Image: "gcr.io/my-project/my-app:0.0.1"},
+		{Name: "worker", Image: "gcr.io/my-project/my-worker:0.0.2@sha256:abcdef"},
+		{Name: "untagged", Image: "localhost:5000/my-app"},
+	}
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		containers  []corev1.Container
+		wantName    string
+		wantTag     string
+	}{
+		{"skips sidecars", nil, containers, "my-app", "0.0.1"},
+		{"annotation", map[string]string{kube.AnnotationPrimaryContainer: "worker"}, containers, "worker", "0.0.2"},
+		{"annotation without tag", map[string]string{kube.AnnotationPrimaryContainer: "untagged"}, containers, "untagged", ""},
+		{"unknown annotation falls back", map[string]string{kube.AnnotationPrimaryContainer: "missing"}, containers, "my-app", "0.0.1"},
+		{"no containers", nil, nil, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Deployment{Deployment: &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "my-app",
+					Annotations: tt.annotations,
+				},
+				Spec: appsv1.DeploymentSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: tt.containers,
+						},
+					},
+				},
+			}}
+			c := d.PrimaryContainer()
+			if tt.wantName == "" {
+				assert.Nil(t, c)
+			} else {
+				require.NotNil(t, c)
+				assert.Equal(t, tt.wantName, c.Name)
+			}
+			assert.Equal(t, tt.wantTag, d.PrimaryImageTag())
+		})
+	}
+}
+
+func TestDeploymentVersionDrift(t *testing.T) {
+	tests := []struct {
+		name      string
+		version   string
+		image     string
+		wantDrift bool
+	}{
+		{"matching", "0.0.1", "gcr.io/my-project/my-app:0.0.1", false},
+		{"matching with prefix", "0.0.1", "gcr.io/my-project/my-app:v0.0.1", false},
+		{"mismatching", "0.0.2", "gcr.io/my-project/my-app:0.0.1", true},
+		{"no version", "", "gcr.io/my-project/my-app:0.0.1", false},
+		{"no tag", "0.0.1", "gcr.io/my-project/my-app", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels := map[string]string{}
+			if tt.version != "" {
+				labels["version"] = tt.version
+			}
+			d := Deployment{Deployment: &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "my-app",
+					Labels: labels,
+				},
+				Spec: appsv1.DeploymentSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{Name: "my-app", Image: tt.image}},
+						},
+					},
+				},
+			}}
+			labelVersion, imageTag, drift := d.VersionDrift()
+			assert.Equal(t, tt.version, labelVersion)
+			assert.Equal(t, d.PrimaryImageTag(), imageTag)
+			assert.Equal(t, tt.wantDrift, drift)
+		})
+	}
+}
+
+func TestDeploymentReplicas(t *testing.T) {
+	three := int32(3)
+	tests := []struct {
+		name                                string
+		replicas                            *int32
+		current                             int32
+		ready                               int32
+		wantDesired, wantCurrent, wantReady int32
+		wantPods                            string
+	}{
+		{"desired replicas", &three, 3, 2, 3, 3, 2, "2/3"},
+		{"nil replicas default to one", nil, 1, 1, 1, 1, 1, "1/1"},
+		{"no ready replicas", &three, 1, 0, 3, 1, 0, ""},
+	}
+
+	for _, test := range tests {
+		d := Deployment{Deployment: &appsv1.Deployment{
+			Spec: appsv1.DeploymentSpec{
+				Replicas: test.replicas,
+			},
+			Status: appsv1.DeploymentStatus{
+				Replicas:      test.current,
+				ReadyReplicas: test.ready,
+			},
+		}}
+
+		desired, current, ready := d.Replicas()
+		assert.Equal(t, test.wantDesired, desired, test.name)
+		assert.Equal(t, test.wantCurrent, current, test.name)
+		assert.Equal(t, test.wantReady, ready, test.name)
+		assert.Equal(t, test.wantPods, d.Pods(), test.name)
+	}
+}
+
+func TestListRegistries(t *testing.T) {
+	deployment := func(images ...string) Deployment {
+		d := &appsv1.Deployment{}
+		for _, image := range images {
+			d.Spec.Template.Spec.Containers = append(d.Spec.Template.Spec.Containers, corev1.Container{Image: image})
+		}
+		return Deployment{Deployment: d}
+	}
+	list := List{
+		Items: []Application{
+			{
+				&v1.SourceRepository{Spec: v1.SourceRepositorySpec{Repo: "my-app"}},
+				map[string]Environment{
+					"staging": {
+						v1.Environment{},
+						[]Deployment{deployment("gcr.io/my-project/my-app:1.0.1", "nginx:1.17")},
+					},
+					"production": {
+						v1.Environment{},
+						[]Deployment{deployment("gcr.io/my-project/my-app:1.0.0")},
+					},
+				},
+			},
+			{
+				&v1.SourceRepository{Spec: v1.SourceRepositorySpec{Repo: "another-app"}},
+				map[string]Environment{
+					"staging": {
+						v1.Environment{},
+						[]Deployment{deployment("jenkinsxio/another-app:0.0.1", "registry.example.com:5000/sidecar")},
+					},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, []string{"docker.io", "gcr.io", "registry.example.com:5000"}, list.Registries())
+}
+
+func TestDeploymentPodIssues(t *testing.T) {
+	labels := map[string]string{"app": "my-app"}
+	pod := func(name string, podLabels map[string]string, state corev1.ContainerState) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "jx-staging",
+				Labels:    podLabels,
+			},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name:  "my-app",
+						State: state,
+					},
+				},
+			},
+		}
+	}
+	kc := fake.NewSimpleClientset(
+		pod("my-app-1", labels, corev1.ContainerState{
+			Waiting: &corev1.ContainerStateWaiting{
+				Reason:  "ImagePullBackOff",
+				Message: "Back-off pulling image \"my-app:0.0.1\"",
+			},
+		}),
+		pod("my-app-2", labels, corev1.ContainerState{
+			Running: &corev1.ContainerStateRunning{},
+		}),
+		pod("another-app-1", map[string]string{"app": "another-app"}, corev1.ContainerState{
+			Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"},
+		}),
+	)
+	d := Deployment{Deployment: &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-app",
+			Namespace: "jx-staging",
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+		},
+	}}
+
+	assert.Equal(t, []string{`pod my-app-1 container my-app is in ImagePullBackOff: Back-off pulling image "my-app:0.0.1"`}, d.PodIssues(kc))
+}
+
+func TestDeploymentPreviewURL(t *testing.T) {
+	kc := fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "my-app",
+				Namespace: "jx-my-org-my-app-pr-1",
+			},
+		},
+		&extensionsv1beta1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "preview",
+				Namespace: "jx-my-org-my-app-pr-1",
+			},
+			Spec: extensionsv1beta1.IngressSpec{
+				Rules: []extensionsv1beta1.IngressRule{
+					{
+						Host: "my-app.jx-my-org-my-app-pr-1.example.com",
+						IngressRuleValue: extensionsv1beta1.IngressRuleValue{
+							HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
+								Paths: []extensionsv1beta1.HTTPIngressPath{
+									{
+										Backend: extensionsv1beta1.IngressBackend{
+											ServiceName: "my-app",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	)
+	app := Application{
+		&v1.SourceRepository{Spec: v1.SourceRepositorySpec{Repo: "my-app"}},
+		map[string]Environment{},
+	}
+	preview := Environment{
+		v1.Environment{
+			Spec: v1.EnvironmentSpec{
+				Namespace: "jx-my-org-my-app-pr-1",
+				Kind:      v1.EnvironmentKindTypePreview,
+			},
+		},
+		nil,
+	}
+	d := Deployment{Deployment: &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-app",
+			Namespace: "jx-my-org-my-app-pr-1",
+		},
+	}}
+
+	assert.Equal(t, "http://my-app.jx-my-org-my-app-pr-1.example.com", d.PreviewURL(kc, app))
+	assert.Equal(t, "http://my-app.jx-my-org-my-app-pr-1.example.com", d.URLInEnvironment(kc, app, preview))
+}
+
+func TestListUndeployed(t *testing.T) {
+	list := List{
+		Items: []Application{
+			{
+				&v1.SourceRepository{Spec: v1.SourceRepositorySpec{Repo: "deployed-app"}},
+				map[string]Environment{
+					"staging": {
+						v1.Environment{},
+						[]Deployment{{Deployment: &appsv1.Deployment{}}},
+					},
+				},
+			},
+			{
+				&v1.SourceRepository{Spec: v1.SourceRepositorySpec{Repo: "undeployed-app"}},
+				map[string]Environment{},
+			},
+		},
+	}
+
+	undeployed := list.Undeployed()
+	require.Len(t, undeployed, 1)
+	assert.Equal(t, "undeployed-app", undeployed[0].Name())
+}
+
+func TestListUnhealthy(t *testing.T) {
+	three := int32(3)
+	deployment := func(ready int32) Deployment {
+		return Deployment{Deployment: &appsv1.Deployment{
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &three,
+			},
+			Status: appsv1.DeploymentStatus{
+				ReadyReplicas: ready,
+			},
+		}}
+	}
+	app := func(name string, deployments ...Deployment) Application {
+		return Application{
+			&v1.SourceRepository{Spec: v1.SourceRepositorySpec{Repo: name}},
+			map[string]Environment{
+				"staging": {
+					v1.Environment{},
+					deployments,
+				},
+			},
+		}
+	}
+	list := List{
+		Items: []Application{
+			app("healthy-app", deployment(3)),
+			app("degraded-app", deployment(3), deployment(1)),
+			app("down-app", deployment(0)),
+			app("undeployed-app"),
+		},
+	}
+
+	unhealthy := list.Unhealthy(1)
+	names := []string{}
+	for _, a := range unhealthy.Items {
+		names = append(names, a.Name())
+	}
+	assert.Equal(t, []string{"degraded-app", "down-app"}, names)
+
+	unhealthy = list.Unhealthy(0.3)
+	require.Len(t, unhealthy.Items, 1)
+	assert.Equal(t, "down-app", unhealthy.Items[0].Name())
+}
+
+func TestListSourceRepositoriesPages(t *testing.T) {
+	page := func(cont string, names ...string) *v1.SourceRepositoryList {
+		list := &v1.SourceRepositoryList{}
+		list.Continue = cont
+		for _, name := range names {
+			list.Items = append(list.Items, v1.SourceRepository{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: "jx",
+				},
+			})
+		}
+		return list
+	}
+	pages := []*v1.SourceRepositoryList{
+		page("page-2", "repo-1", "repo-2"),
+		page("page-3", "repo-3", "repo-4"),
+		page("", "repo-5"),
+	}
+
+	client := jxfake.NewSimpleClientset()
+	calls := 0
+	client.PrependReactor("list", "sourcerepositories", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		answer := pages[calls]
+		calls++
+		return true, answer, nil
+	})
+
+	srs, err := listSourceRepositories(client, "jx")
+	require.NoError(t, err)
+
+	assert.Equal(t, len(pages), calls)
+	names := []string{}
+	for _, sr := range srs {
+		names = append(names, sr.Name)
+	}
+	assert.Equal(t, []string{"repo-1", "repo-2", "repo-3", "repo-4", "repo-5"}, names)
+}
+
+func TestListResolveURLs(t *testing.T) {
+	service := func(name, ns string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   ns,
+				Annotations: map[string]string{services.ExposeURLAnnotation: fmt.Sprintf("http://%s.%s.example.com", name, ns)},
+			},
+		}
+	}
+	kc := fake.NewSimpleClientset(
+		service("my-app", "jx-staging"),
+		service("another-app", "jx-staging"),
+		service("my-app", "jx-production"),
+	)
+	deployment := func(ns string) Deployment {
+		return Deployment{Deployment: &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: ns}}}
+	}
+	environment := func(ns string) Environment {
+		return Environment{
+			v1.Environment{Spec: v1.EnvironmentSpec{Namespace: ns}},
+			[]Deployment{deployment(ns)},
+		}
+	}
+	myApp := Application{
+		&v1.SourceRepository{Spec: v1.SourceRepositorySpec{Repo: "my-app"}},
+		map[string]Environment{
+			"staging":    environment("jx-staging"),
+			"production": environment("jx-production"),
+		},
+	}
+	anotherApp := Application{
+		&v1.SourceRepository{Spec: v1.SourceRepositorySpec{Repo: "another-app"}},
+		map[string]Environment{
+			"staging":    environment("jx-staging"),
+			"production": environment("jx-production"),
+		},
+	}
+	list := List{Items: []Application{myApp, anotherApp}}
+
+	resolved, err := list.ResolveURLs(kc)
+	require.NoError(t, err)
+	require.Len(t, resolved.Items, 2)
+
+	serviceLists := 0
+	for _, action := range kc.Actions() {
+		if action.GetVerb() == "list" && action.GetResource().Resource == "services" {
+			serviceLists++
+		}
+	}
+	assert.Equal(t, 2, serviceLists)
+
+	kc.ClearActions()
+	myApp, anotherApp = resolved.Items[0], resolved.Items[1]
+	assert.Equal(t, "http://my-app.jx-staging.example.com", myApp.Environments["staging"].Deployments[0].URL(kc, myApp))
+	assert.Equal(t, "http://my-app.jx-production.example.com", myApp.Environments["production"].Deployments[0].URL(kc, myApp))
+	assert.Equal(t, "http://another-app.jx-staging.example.com", anotherApp.Environments["staging"].Deployments[0].URL(kc, anotherApp))
+	assert.Equal(t, "", anotherApp.Environments["production"].Deployments[0].URL(kc, anotherApp))
+	assert.Empty(t, kc.Actions())
+
+	// the original list is not modified
+	assert.Nil(t, list.Items[0].Environments["staging"].Deployments[0].url)
+}
+
+func TestListEnvironmentColumns(t *testing.T) {
+	environment := func(name string, kind v1.EnvironmentKindType, order int32) Environment {
+		return Environment{
+			v1.Environment{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Spec: v1.EnvironmentSpec{
+					Kind:  kind,
+					Order: order,
+				},
+			},
+			nil,
+		}
+	}
+	list := List{
+		Items: []Application{
+			{
+				&v1.SourceRepository{Spec: v1.SourceRepositorySpec{Repo: "my-app"}},
+				map[string]Environment{
+					"production": environment("production", v1.EnvironmentKindTypePermanent, 200),
+					"staging":    environment("staging", v1.EnvironmentKindTypePermanent, 100),
+				},
+			},
+			{
+				&v1.SourceRepository{Spec: v1.SourceRepositorySpec{Repo: "another-app"}},
+				map[string]Environment{
+					"dev":     environment("dev", v1.EnvironmentKindTypeDevelopment, 0),
+					"staging": environment("staging", v1.EnvironmentKindTypePermanent, 100),
+					"pr-1":    environment("pr-1", v1.EnvironmentKindTypePreview, 0),
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, []string{"dev", "staging", "production"}, list.EnvironmentColumns())
+}
+
+func TestListServiceDomainTemplate(t *testing.T) {
+	environment := func(name string, namespace string) Environment {
+		return Environment{
+			v1.Environment{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Spec: v1.EnvironmentSpec{
+					Kind:      v1.EnvironmentKindTypePermanent,
+					Namespace: namespace,
+				},
+			},
+			nil,
+		}
+	}
+	requirements := config.NewRequirementsConfig()
+	requirements.Ingress.Domain = "example.com"
+	requirements.Environments = []config.EnvironmentConfig{
+		{Key: "production", Ingress: config.IngressConfig{Domain: "prod.example.com"}},
+	}
+	list := List{
+		Items: []Application{
+			{
+				&v1.SourceRepository{Spec: v1.SourceRepositorySpec{Repo: "my-app"}},
+				map[string]Environment{
+					"staging":    environment("staging", "jx-staging"),
+					"production": environment("production", "jx-production"),
+				},
+			},
+		},
+		DevRequirements: requirements,
+	}
+
+	assert.Equal(t, "{{.Service}}.jx-staging.example.com", list.ServiceDomainTemplate("staging"))
+	assert.Equal(t, "{{.Service}}.jx-production.prod.example.com", list.ServiceDomainTemplate("production"))
+	assert.Equal(t, "", list.ServiceDomainTemplate("unknown"))
+
+	list.DevRequirements = nil
+	assert.Equal(t, "", list.ServiceDomainTemplate("staging"))
+}
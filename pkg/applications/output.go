@@ -0,0 +1,93 @@
+package applications
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Summary is the serialized form of an application and the environments it is deployed to
+type Summary struct {
+	Name         string               `json:"name"`
+	Environments []EnvironmentSummary `json:"environments,omitempty"`
+}
+
+// EnvironmentSummary is the serialized form of an application deployment in a single environment
+type EnvironmentSummary struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Pods      string `json:"pods,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Remote    bool   `json:"remote,omitempty"`
+}
+
+// Summaries returns the applications and their environments sorted by name. The URLs are only resolved if
+// a kube client is given
+func (l List) Summaries(kc kubernetes.Interface) []Summary {
+	answer := make([]Summary, 0, len(l.Items))
+	for _, a := range l.Items {
+		summary := Summary{
+			Name: a.Name(),
+		}
+		for _, envName := range a.sortedEnvironmentNames() {
+			env := a.Environments[envName]
+			for _, d := range env.Deployments {
+				envSummary := EnvironmentSummary{
+					Name:      envName,
+					Namespace: env.Spec.Namespace,
+					Version:   d.Version(),
+					Pods:      d.Pods(),
+					Remote:    env.IsRemote(),
+				}
+				if kc != nil {
+					envSummary.URL = d.URLInEnvironment(kc, a, env)
+				}
+				summary.Environments = append(summary.Environments, envSummary)
+			}
+		}
+		answer = append(answer, summary)
+	}
+	sort.SliceStable(answer, func(i, j int) bool {
+		return answer[i].Name < answer[j].Name
+	})
+	return answer
+}
+
+// MarshalJSON serializes the list as its summaries without resolving the URLs
+func (l List) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.Summaries(nil))
+}
+
+// ToJSON serializes the list as JSON, resolving the URLs if a kube client is given
+func (l List) ToJSON(kc kubernetes.Interface) ([]byte, error) {
+	return json.MarshalIndent(l.Summaries(kc), "", "  ")
+}
+
+// ToYAML serializes the list as YAML, resolving the URLs if a kube client is given
+func (l List) ToYAML(kc kubernetes.Interface) ([]byte, error) {
+	return yaml.Marshal(l.Summaries(kc))
+}
+
+// ToTable returns the list as table rows with a header row followed by a row for each application deployment,
+// resolving the URLs if a kube client is given
+func (l List) ToTable(kc kubernetes.Interface) [][]string {
+	rows := [][]string{{"APPLICATION", "ENVIRONMENT", "VERSION", "PODS", "URL"}}
+	for _, s := range l.Summaries(kc) {
+		for _, e := range s.Environments {
+			rows = append(rows, []string{s.Name, e.Name, e.Version, e.Pods, e.URL})
+		}
+	}
+	return rows
+}
+
+func (a Application) sortedEnvironmentNames() []string {
+	names := make([]string, 0, len(a.Environments))
+	for name := range a.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
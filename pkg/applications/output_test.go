@@ -0,0 +1,126 @@
+// +build unit
+
+package applications
+
+import (
+	"encoding/json"
+	"testing"
+
+	v1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestList() List {
+	replicas := int32(2)
+	deployment := func(ns, version string) Deployment {
+		return Deployment{Deployment: &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "jx-my-app",
+				Namespace: ns,
+				Labels: map[string]string{
+					"version": version,
+				},
+			},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+			},
+			Status: appsv1.DeploymentStatus{
+				ReadyReplicas: 1,
+			},
+		}}
+	}
+	environment := func(name, ns string, d Deployment) Environment {
+		return Environment{
+			v1.Environment{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Spec:       v1.EnvironmentSpec{Namespace: ns},
+			},
+			[]Deployment{d},
+		}
+	}
+	return List{
+		Items: []Application{
+			{
+				&v1.SourceRepository{Spec: v1.SourceRepositorySpec{Repo: "my-app"}},
+				map[string]Environment{
+					"staging":    environment("staging", "jx-staging", deployment("jx-staging", "1.0.2")),
+					"production": environment("production", "jx-production", deployment("jx-production", "1.0.1")),
+				},
+			},
+			{
+				&v1.SourceRepository{Spec: v1.SourceRepositorySpec{Repo: "another-app"}},
+				map[string]Environment{},
+			},
+		},
+	}
+}
+
+func TestListMarshalJSON(t *testing.T) {
+	data, err := json.Marshal(newTestList())
+	require.NoError(t, err)
+
+	expected := `[
+		{"name": "another-app"},
+		{"name": "my-app", "environments": [
+			{"name": "production", "namespace": "jx-production", "version": "1.0.1", "pods": "1/2"},
+			{"name": "staging", "namespace": "jx-staging", "version": "1.0.2", "pods": "1/2"}
+		]}
+	]`
+	assert.JSONEq(t, expected, string(data))
+}
+
+func TestListToYAML(t *testing.T) {
+	data, err := newTestList().ToYAML(nil)
+	require.NoError(t, err)
+
+	expected := `- name: another-app
+- environments:
+  - name: production
+    namespace: jx-production
+    pods: 1/2
+    version: 1.0.1
+  - name: staging
+    namespace: jx-staging
+    pods: 1/2
+    version: 1.0.2
+  name: my-app
+`
+	assert.Equal(t, expected, string(data))
+}
+
+func TestListToTable(t *testing.T) {
+	rows := newTestList().ToTable(nil)
+
+	expected := [][]string{
+		{"APPLICATION", "ENVIRONMENT", "VERSION", "PODS", "URL"},
+		{"my-app", "production", "1.0.1", "1/2", ""},
+		{"my-app", "staging", "1.0.2", "1/2", ""},
+	}
+	assert.Equal(t, expected, rows)
+}
+
+func TestEnvironmentIsRemote(t *testing.T) {
+	list := newTestList()
+	production := list.Items[0].Environments["production"]
+	assert.False(t, production.IsRemote())
+
+	production.Spec.RemoteCluster = true
+	list.Items[0].Environments["production"] = production
+	assert.True(t, production.IsRemote())
+	assert.False(t, list.Items[0].Environments["staging"].IsRemote())
+
+	data, err := json.Marshal(list)
+	require.NoError(t, err)
+
+	expected := `[
+		{"name": "another-app"},
+		{"name": "my-app", "environments": [
+			{"name": "production", "namespace": "jx-production", "version": "1.0.1", "pods": "1/2", "remote": true},
+			{"name": "staging", "namespace": "jx-staging", "version": "1.0.2", "pods": "1/2"}
+		]}
+	]`
+	assert.JSONEq(t, expected, string(data))
+}
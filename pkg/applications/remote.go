@@ -0,0 +1,359 @@
+package applications
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	v1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/cloud"
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/gits"
+	"github.com/jenkins-x/jx/pkg/kube"
+	"github.com/jenkins-x/jx/pkg/log"
+	"github.com/jenkins-x/jx/pkg/util"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	// this is so that we load the auth plugins so we can connect to, say, GCP
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+)
+
+const (
+	// gcloudBinaryEnvVar the environment variable which overrides the gcloud binary used to fetch the credentials of
+	// GKE clusters
+	gcloudBinaryEnvVar = "JX_GCLOUD_BINARY"
+
+	// kubeConfigCacheDirEnvVar the environment variable which overrides the base directory the kubeconfig files of
+	// remote clusters are cached in, e.g. to isolate each run on a CI runner sharing its home directory
+	kubeConfigCacheDirEnvVar = "JX_KUBECONFIG_CACHE_DIR"
+)
+
+// GetRemoteDeployments fetches the deployments of an environment running in a remote cluster by loading the
+// requirements from the environment's git repository and connecting to the cluster they describe
+func GetRemoteDeployments(gitter gits.Gitter, env *v1.Environment) (map[string]appsv1.Deployment, error) {
+	return getRemoteDeployments(NewRequirementsResolver(gitter, nil), nil, env)
+}
+
+// getRemoteDeployments fetches the deployments of a remote environment, reusing the requirements of the memo if there
+// are any
+func getRemoteDeployments(resolver RequirementsResolver, memo *RequirementsMemo, env *v1.Environment) (map[string]appsv1.Deployment, error) {
+	requirements, err := getRequirementsForEnvironment(resolver, memo, env)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting the requirements of environment %s", env.Name)
+	}
+	kubeClient, err := getKubeClientFromRequirements(requirements)
+	if err != nil {
+		return nil, errors.Wrapf(err, "connecting to the cluster of environment %s", env.Name)
+	}
+	return kube.GetDeployments(kubeClient, env.Spec.Namespace)
+}
+
+// RequirementsResolver resolves the requirements of an environment, e.g. to supply canned requirements in tests
+// without a cluster or git repository
+type RequirementsResolver interface {
+	GetRequirements(env *v1.Environment) (*config.RequirementsConfig, error)
+}
+
+// RequirementsResolverFunc allows a function to be used as a RequirementsResolver
+type RequirementsResolverFunc func(env *v1.Environment) (*config.RequirementsConfig, error)
+
+// GetRequirements invokes the function
+func (f RequirementsResolverFunc) GetRequirements(env *v1.Environment) (*config.RequirementsConfig, error) {
+	return f(env)
+}
+
+// NewRequirementsResolver creates the default RequirementsResolver which loads the requirements of an environment
+// from its team settings, falling back to the requirements in its git repository. If a cache is specified its clones
+// are reused
+func NewRequirementsResolver(gitter gits.Gitter, cache *RequirementsCache) RequirementsResolver {
+	return &defaultRequirementsResolver{
+		gitter: gitter,
+		cache:  cache,
+	}
+}
+
+// defaultRequirementsResolver loads the requirements from the team settings or git repository of the environment
+type defaultRequirementsResolver struct {
+	gitter gits.Gitter
+	cache  *RequirementsCache
+}
+
+// GetRequirements returns the requirements of the environment
+func (r *defaultRequirementsResolver) GetRequirements(env *v1.Environment) (*config.RequirementsConfig, error) {
+	if env.Spec.TeamSettings.BootRequirements != "" {
+		return config.GetRequirementsConfigFromTeamSettings(&env.Spec.TeamSettings)
+	}
+	if r.cache != nil {
+		return r.cache.GetRequirementsFromGit(r.gitter, env.Spec.Source.URL, env.Spec.Source.Ref)
+	}
+	return GetRequirementsFromGit(r.gitter, env.Spec.Source.URL, env.Spec.Source.Ref)
+}
+
+// RequirementsMemo memoizes the requirements of environments within the scope of a single request, such as listing
+// the applications, so that environments sharing the same team settings or git repository only load them once
+type RequirementsMemo struct {
+	lock    sync.Mutex
+	entries map[string]*requirementsMemoEntry
+}
+
+// requirementsMemoEntry the requirements loaded for a key
+type requirementsMemoEntry struct {
+	once         sync.Once
+	requirements *config.RequirementsConfig
+	err          error
+}
+
+// NewRequirementsMemo creates an empty memo
+func NewRequirementsMemo() *RequirementsMemo {
+	return &RequirementsMemo{
+		entries: map[string]*requirementsMemoEntry{},
+	}
+}
+
+// load returns the requirements memoized for the key, invoking the function the first time the key is used
+func (m *RequirementsMemo) load(key string, fn func() (*config.RequirementsConfig, error)) (*config.RequirementsConfig, error) {
+	if m == nil {
+		return fn()
+	}
+	m.lock.Lock()
+	entry := m.entries[key]
+	if entry == nil {
+		entry = &requirementsMemoEntry{}
+		m.entries[key] = entry
+	}
+	m.lock.Unlock()
+
+	entry.once.Do(func() {
+		entry.requirements, entry.err = fn()
+	})
+	return entry.requirements, entry.err
+}
+
+// GetRequirementsForEnvironment returns the requirements of the environment using the resolver, which defaults to
+// loading them from its team settings, falling back to the requirements in its git repository. If a memo is
+// specified the requirements are only resolved once per team settings or git URL and ref
+func GetRequirementsForEnvironment(gitter gits.Gitter, env *v1.Environment, memo *RequirementsMemo, resolver RequirementsResolver) (*config.RequirementsConfig, error) {
+	if resolver == nil {
+		resolver = NewRequirementsResolver(gitter, nil)
+	}
+	return getRequirementsForEnvironment(resolver, memo, env)
+}
+
+// getRequirementsForEnvironment returns the requirements of the environment resolved by the resolver, memoized by
+// the source of the requirements
+func getRequirementsForEnvironment(resolver RequirementsResolver, memo *RequirementsMemo, env *v1.Environment) (*config.RequirementsConfig, error) {
+	key := "git#" + env.Spec.Source.URL + "#" + env.Spec.Source.Ref
+	if bootRequirements := env.Spec.TeamSettings.BootRequirements; bootRequirements != "" {
+		key = "teamSettings#" + bootRequirements
+	}
+	return memo.load(key, func() (*config.RequirementsConfig, error) {
+		return resolver.GetRequirements(env)
+	})
+}
+
+// GetRequirementsFromGit clones the git repository and loads the requirements in its root directory. If a ref such
+// as a branch, tag or commit SHA is specified it is checked out, otherwise the default branch is used
+func GetRequirementsFromGit(gitter gits.Gitter, gitURL string, ref string) (*config.RequirementsConfig, error) {
+	if gitURL == "" {
+		return nil, fmt.Errorf("no git URL to load the requirements from")
+	}
+	dir, err := ioutil.TempDir("", "jx-requirements-")
+	if err != nil {
+		return nil, errors.Wrap(err, "creating a temporary directory")
+	}
+	defer os.RemoveAll(dir)
+	err = cloneRequirementsRepository(gitter, gitURL, ref, dir)
+	if err != nil {
+		return nil, err
+	}
+	return config.LoadRequirementsConfigFile(filepath.Join(dir, config.RequirementsConfigFileName))
+}
+
+// cloneRequirementsRepository clones the git repository into the directory, checking out the ref if there is one.
+// As only the requirements are needed a shallow clone is attempted first, falling back to a full clone
+func cloneRequirementsRepository(gitter gits.Gitter, gitURL string, ref string, dir string) error {
+	log.Logger().Debugf("shallow cloning %s into %s", gitURL, dir)
+	var err error
+	if ref == "" {
+		err = shallowCloneDefaultBranch(gitter, gitURL, dir)
+	} else {
+		err = gitter.ShallowClone(dir, gitURL, ref, "")
+	}
+	if err == nil {
+		return nil
+	}
+	log.Logger().Debugf("failed to shallow clone %s, falling back to a full clone: %s", gitURL, err)
+	err = os.RemoveAll(dir)
+	if err != nil {
+		return errors.Wrapf(err, "removing the shallow clone %s", dir)
+	}
+	err = os.MkdirAll(dir, util.DefaultWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "creating directory %s", dir)
+	}
+
+	log.Logger().Debugf("cloning %s into %s", gitURL, dir)
+	err = gitter.Clone(gitURL, dir)
+	if err != nil {
+		return errors.Wrapf(err, "cloning %s", gitURL)
+	}
+	if ref != "" {
+		err = gitter.Checkout(dir, ref)
+		if err != nil {
+			return errors.Wrapf(err, "checking out %s of %s", ref, gitURL)
+		}
+	}
+	return nil
+}
+
+// shallowCloneDefaultBranch shallow clones the default branch of the git repository into the directory by fetching the
+// remote HEAD, as a shallow clone without a commitish uses the master branch which may not exist or may be stale
+func shallowCloneDefaultBranch(gitter gits.Gitter, gitURL string, dir string) error {
+	err := gitter.Init(dir)
+	if err != nil {
+		return errors.Wrapf(err, "initialising a git repository in %s", dir)
+	}
+	err = gitter.AddRemote(dir, "origin", gitURL)
+	if err != nil {
+		return errors.Wrapf(err, "adding the remote %s in %s", gitURL, dir)
+	}
+	err = gitter.FetchBranchShallow(dir, "origin", "HEAD")
+	if err != nil {
+		return errors.Wrapf(err, "fetching the default branch of %s", gitURL)
+	}
+	return gitter.Reset(dir, "FETCH_HEAD", true)
+}
+
+// getKubeClientFromRequirements creates a kube client for the cluster described by the requirements, using the
+// named context in the local kubeconfig if there is one. If the requirements do not specify the provider it is
+// inferred from the server URL of the current context in the local kubeconfig
+func getKubeClientFromRequirements(requirements *config.RequirementsConfig) (kubernetes.Interface, error) {
+	var restConfig *rest.Config
+	var err error
+	cluster := requirements.Cluster
+	provider := cluster.Provider
+	if provider == "" && cluster.KubeContext == "" {
+		provider = providerFromKubeConfig()
+	}
+	switch {
+	case cluster.KubeContext != "":
+		restConfig, err = kubeConfigForContext(cluster.KubeContext)
+	case provider == cloud.GKE:
+		var kubeConfig string
+		kubeConfig, err = GetWorkspaceKubeConfigGKE(cluster, "")
+		if err != nil {
+			return nil, err
+		}
+		restConfig, err = clientcmd.BuildConfigFromFlags("", kubeConfig)
+	default:
+		return nil, fmt.Errorf("unsupported provider %q for remote clusters, specify the cluster.kubeContext in the requirements to use a context in your kubeconfig", provider)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(restConfig)
+}
+
+// providerFromKubeConfig infers the provider from the server URL of the current context in the local kubeconfig,
+// returning an empty string if it cannot be inferred
+func providerFromKubeConfig() string {
+	restConfig, err := kubeConfigForContext("")
+	if err != nil {
+		log.Logger().Debugf("failed to load the kubeconfig to infer the provider: %s", err)
+		return ""
+	}
+	provider := cloud.ProviderFromServerURL(restConfig.Host)
+	log.Logger().Debugf("inferred the provider %q from the kubeconfig server %s", provider, restConfig.Host)
+	return provider
+}
+
+// kubeConfigForContext loads the client configuration of the named context in the local kubeconfig
+func kubeConfigForContext(kubeContext string) (*rest.Config, error) {
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		clientcmd.NewDefaultClientConfigLoadingRules(),
+		&clientcmd.ConfigOverrides{CurrentContext: kubeContext}).ClientConfig()
+	if err != nil {
+		return nil, errors.Wrapf(err, "loading the kubeconfig context %s", kubeContext)
+	}
+	return restConfig, nil
+}
+
+// GetWorkspaceKubeConfigGKE returns the path of a kubeconfig file for the GKE cluster, fetching the credentials
+// via gcloud the first time the cluster is used. The kubeconfig files are cached in the given base directory so that
+// they can be isolated per run, defaulting to $JX_KUBECONFIG_CACHE_DIR and then the jx config directory if it is
+// empty. The gcloud binary can be overridden via $JX_GCLOUD_BINARY or the gke configuration of the cluster, which can
+// also specify additional arguments. Autopilot clusters are regional so they require a region rather than a zone
+func GetWorkspaceKubeConfigGKE(cluster config.ClusterConfig, baseDir string) (string, error) {
+	if cluster.ProjectID == "" || cluster.ClusterName == "" {
+		return "", fmt.Errorf("the project and cluster name are required to connect to a GKE cluster")
+	}
+	if cluster.GKEConfig != nil && cluster.GKEConfig.Autopilot {
+		if cluster.Zone != "" {
+			return "", fmt.Errorf("GKE Autopilot cluster %s is regional, specify its region rather than the zone %s", cluster.ClusterName, cluster.Zone)
+		}
+		if cluster.Region == "" {
+			return "", fmt.Errorf("a region is required to connect to GKE Autopilot cluster %s", cluster.ClusterName)
+		}
+	}
+	locationFlag, location := "--zone", cluster.Zone
+	if location == "" {
+		locationFlag, location = "--region", cluster.Region
+	}
+	if location == "" {
+		return "", fmt.Errorf("a zone or region is required to connect to GKE cluster %s", cluster.ClusterName)
+	}
+
+	if baseDir == "" {
+		baseDir = os.Getenv(kubeConfigCacheDirEnvVar)
+	}
+	if baseDir == "" {
+		configDir, err := util.ConfigDir()
+		if err != nil {
+			return "", err
+		}
+		baseDir = configDir
+	}
+	dir := filepath.Join(baseDir, "kubeconfig", "gke", cluster.ProjectID, location, cluster.ClusterName)
+	err := os.MkdirAll(dir, util.DefaultWritePermissions)
+	if err != nil {
+		return "", errors.Wrapf(err, "creating directory %s", dir)
+	}
+	kubeConfig := filepath.Join(dir, "config")
+	exists, err := util.FileExists(kubeConfig)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return kubeConfig, nil
+	}
+
+	binary := os.Getenv(gcloudBinaryEnvVar)
+	args := []string{"container", "clusters", "get-credentials", cluster.ClusterName, locationFlag, location, "--project", cluster.ProjectID}
+	if cluster.GKEConfig != nil {
+		if binary == "" {
+			binary = cluster.GKEConfig.GCloudBinary
+		}
+		args = append(args, cluster.GKEConfig.GCloudArgs...)
+	}
+	if binary == "" {
+		binary = "gcloud"
+	}
+	cmd := util.Command{
+		Name: binary,
+		Args: args,
+		Env: map[string]string{
+			"KUBECONFIG": kubeConfig,
+		},
+	}
+	_, err = cmd.RunWithoutRetry()
+	if err != nil {
+		return "", errors.Wrapf(err, "getting the credentials of GKE cluster %s", cluster.ClusterName)
+	}
+	return kubeConfig, nil
+}
@@ -0,0 +1,508 @@
+// +build unit
+
+package applications
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	v1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/gits"
+	"github.com/jenkins-x/jx/pkg/kube"
+	"github.com/jenkins-x/jx/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://dev.example.com
+  name: dev
+- cluster:
+    server: https://staging.example.com
+  name: staging
+contexts:
+- context:
+    cluster: dev
+    user: dev
+  name: dev
+- context:
+    cluster: staging
+    user: staging
+  name: remote-staging
+current-context: dev
+users:
+- name: dev
+  user:
+    token: dev-token
+- name: staging
+  user:
+    token: staging-token
+`
+
+func TestGetKubeClientFromRequirementsWithKubeContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-remote-kubeconfig-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	kubeConfig := filepath.Join(dir, "config")
+	err = ioutil.WriteFile(kubeConfig, []byte(testKubeConfig), 0600)
+	require.NoError(t, err)
+
+	originalKubeConfig, hasKubeConfig := os.LookupEnv("KUBECONFIG")
+	err = os.Setenv("KUBECONFIG", kubeConfig)
+	require.NoError(t, err)
+	defer func() {
+		if hasKubeConfig {
+			os.Setenv("KUBECONFIG", originalKubeConfig)
+		} else {
+			os.Unsetenv("KUBECONFIG")
+		}
+	}()
+
+	restConfig, err := kubeConfigForContext("remote-staging")
+	require.NoError(t, err)
+	assert.Equal(t, "https://staging.example.com", restConfig.Host)
+	assert.Equal(t, "staging-token", restConfig.BearerToken)
+
+	requirements := config.NewRequirementsConfig()
+	requirements.Cluster.Provider = "eks"
+	requirements.Cluster.KubeContext = "remote-staging"
+	kubeClient, err := getKubeClientFromRequirements(requirements)
+	require.NoError(t, err)
+	assert.NotNil(t, kubeClient)
+
+	requirements.Cluster.KubeContext = "does-not-exist"
+	_, err = getKubeClientFromRequirements(requirements)
+	assert.Error(t, err)
+}
+
+func TestGetKubeClientFromRequirementsInfersProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-remote-infer-provider-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the current context of the local kubeconfig is a GKE cluster
+	gkeKubeConfig := filepath.Join(dir, "gke-config")
+	err = ioutil.WriteFile(gkeKubeConfig, []byte(strings.Replace(testKubeConfig, "https://dev.example.com", "https://abc123.gke.goog", 1)), 0600)
+	require.NoError(t, err)
+	otherKubeConfig := filepath.Join(dir, "other-config")
+	err = ioutil.WriteFile(otherKubeConfig, []byte(testKubeConfig), 0600)
+	require.NoError(t, err)
+
+	// the credentials of the remote cluster have already been fetched
+	cacheDir := filepath.Join(dir, "cache")
+	cached := filepath.Join(cacheDir, "kubeconfig", "gke", "my-project", "europe-west1-b", "my-cluster", "config")
+	err = os.MkdirAll(filepath.Dir(cached), 0700)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(cached, []byte(testKubeConfig), 0600)
+	require.NoError(t, err)
+
+	for name, value := range map[string]string{"KUBECONFIG": gkeKubeConfig, kubeConfigCacheDirEnvVar: cacheDir} {
+		original, hasOriginal := os.LookupEnv(name)
+		err = os.Setenv(name, value)
+		require.NoError(t, err)
+		defer func(name string) {
+			if hasOriginal {
+				os.Setenv(name, original)
+			} else {
+				os.Unsetenv(name)
+			}
+		}(name)
+	}
+
+	requirements := config.NewRequirementsConfig()
+	requirements.Cluster.Provider = ""
+	requirements.Cluster.ProjectID = "my-project"
+	requirements.Cluster.ClusterName = "my-cluster"
+	requirements.Cluster.Zone = "europe-west1-b"
+
+	kubeClient, err := getKubeClientFromRequirements(requirements)
+	require.NoError(t, err)
+	assert.NotNil(t, kubeClient)
+
+	// the provider cannot be inferred from other servers
+	err = os.Setenv("KUBECONFIG", otherKubeConfig)
+	require.NoError(t, err)
+	_, err = getKubeClientFromRequirements(requirements)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported provider")
+}
+
+func TestGetWorkspaceKubeConfigGKEWithBaseDir(t *testing.T) {
+	baseDir, err := ioutil.TempDir("", "test-remote-gke-")
+	require.NoError(t, err)
+	defer os.RemoveAll(baseDir)
+
+	cluster := config.ClusterConfig{
+		ProjectID:   "my-project",
+		ClusterName: "my-cluster",
+		Zone:        "europe-west1-b",
+	}
+
+	// an existing kubeconfig is reused rather than fetching the credentials again
+	expected := filepath.Join(baseDir, "kubeconfig", "gke", "my-project", "europe-west1-b", "my-cluster", "config")
+	err = os.MkdirAll(filepath.Dir(expected), 0700)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(expected, []byte(testKubeConfig), 0600)
+	require.NoError(t, err)
+
+	kubeConfig, err := GetWorkspaceKubeConfigGKE(cluster, baseDir)
+	require.NoError(t, err)
+	assert.Equal(t, expected, kubeConfig)
+}
+
+func TestGetWorkspaceKubeConfigGKEWithCacheDirEnvVar(t *testing.T) {
+	baseDir, err := ioutil.TempDir("", "test-remote-gke-env-")
+	require.NoError(t, err)
+	defer os.RemoveAll(baseDir)
+
+	originalCacheDir, hasCacheDir := os.LookupEnv(kubeConfigCacheDirEnvVar)
+	err = os.Setenv(kubeConfigCacheDirEnvVar, baseDir)
+	require.NoError(t, err)
+	defer func() {
+		if hasCacheDir {
+			os.Setenv(kubeConfigCacheDirEnvVar, originalCacheDir)
+		} else {
+			os.Unsetenv(kubeConfigCacheDirEnvVar)
+		}
+	}()
+
+	cluster := config.ClusterConfig{
+		ProjectID:   "my-project",
+		ClusterName: "my-cluster",
+		Zone:        "europe-west1-b",
+	}
+
+	expected := filepath.Join(baseDir, "kubeconfig", "gke", "my-project", "europe-west1-b", "my-cluster", "config")
+	err = os.MkdirAll(filepath.Dir(expected), 0700)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(expected, []byte(testKubeConfig), 0600)
+	require.NoError(t, err)
+
+	kubeConfig, err := GetWorkspaceKubeConfigGKE(cluster, "")
+	require.NoError(t, err)
+	assert.Equal(t, expected, kubeConfig)
+}
+
+func TestGetWorkspaceKubeConfigGKEWithCustomGCloud(t *testing.T) {
+	baseDir, err := ioutil.TempDir("", "test-remote-gke-gcloud-")
+	require.NoError(t, err)
+	defer os.RemoveAll(baseDir)
+
+	// a fake gcloud which records its arguments and writes the kubeconfig
+	argsFile := filepath.Join(baseDir, "args")
+	binary := filepath.Join(baseDir, "my-gcloud")
+	script := "#!/bin/sh\necho \"$@\" > " + argsFile + "\ncat > \"$KUBECONFIG\" <<EOF\n" + testKubeConfig + "\nEOF\n"
+	err = ioutil.WriteFile(binary, []byte(script), 0700)
+	require.NoError(t, err)
+
+	cluster := config.ClusterConfig{
+		ProjectID:   "my-project",
+		ClusterName: "my-cluster",
+		Zone:        "europe-west1-b",
+		GKEConfig: &config.GKEConfig{
+			GCloudBinary: binary,
+			GCloudArgs:   []string{"--impersonate-service-account", "jx@my-project.iam.gserviceaccount.com"},
+		},
+	}
+
+	kubeConfig, err := GetWorkspaceKubeConfigGKE(cluster, baseDir)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(baseDir, "kubeconfig", "gke", "my-project", "europe-west1-b", "my-cluster", "config"), kubeConfig)
+
+	args, err := ioutil.ReadFile(argsFile)
+	require.NoError(t, err)
+	assert.Equal(t, "container clusters get-credentials my-cluster --zone europe-west1-b --project my-project --impersonate-service-account jx@my-project.iam.gserviceaccount.com", strings.TrimSpace(string(args)))
+}
+
+func TestGetWorkspaceKubeConfigGKEAutopilot(t *testing.T) {
+	baseDir, err := ioutil.TempDir("", "test-remote-gke-autopilot-")
+	require.NoError(t, err)
+	defer os.RemoveAll(baseDir)
+
+	argsFile := filepath.Join(baseDir, "args")
+	binary := filepath.Join(baseDir, "my-gcloud")
+	script := "#!/bin/sh\necho \"$@\" > " + argsFile + "\ncat > \"$KUBECONFIG\" <<EOF\n" + testKubeConfig + "\nEOF\n"
+	err = ioutil.WriteFile(binary, []byte(script), 0700)
+	require.NoError(t, err)
+
+	cluster := config.ClusterConfig{
+		ProjectID:   "my-project",
+		ClusterName: "my-autopilot-cluster",
+		Region:      "europe-west1",
+		GKEConfig: &config.GKEConfig{
+			GCloudBinary: binary,
+			Autopilot:    true,
+		},
+	}
+
+	kubeConfig, err := GetWorkspaceKubeConfigGKE(cluster, baseDir)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(baseDir, "kubeconfig", "gke", "my-project", "europe-west1", "my-autopilot-cluster", "config"), kubeConfig)
+
+	args, err := ioutil.ReadFile(argsFile)
+	require.NoError(t, err)
+	assert.Equal(t, "container clusters get-credentials my-autopilot-cluster --region europe-west1 --project my-project", strings.TrimSpace(string(args)))
+
+	// a zone is rejected as Autopilot clusters are regional
+	cluster.Zone = "europe-west1-b"
+	_, err = GetWorkspaceKubeConfigGKE(cluster, baseDir)
+	assert.Error(t, err)
+}
+
+func TestGetRequirementsFromGitWithRef(t *testing.T) {
+	repoDir, err := ioutil.TempDir("", "test-remote-requirements-")
+	require.NoError(t, err)
+	defer os.RemoveAll(repoDir)
+
+	gitter := gits.NewGitCLI()
+	err = gitter.Init(repoDir)
+	require.NoError(t, err)
+
+	commitRequirements := func(clusterName string) {
+		requirements := config.NewRequirementsConfig()
+		requirements.Cluster.ClusterName = clusterName
+		err := requirements.SaveConfig(filepath.Join(repoDir, config.RequirementsConfigFileName))
+		require.NoError(t, err)
+		err = gitter.Add(repoDir, config.RequirementsConfigFileName)
+		require.NoError(t, err)
+		err = gitter.CommitDir(repoDir, "requirements for "+clusterName)
+		require.NoError(t, err)
+	}
+	commitRequirements("pinned")
+	err = gitter.CreateTag(repoDir, "v1.0.0", "pinned requirements")
+	require.NoError(t, err)
+	commitRequirements("latest")
+
+	requirements, err := GetRequirementsFromGit(gitter, repoDir, "")
+	require.NoError(t, err)
+	assert.Equal(t, "latest", requirements.Cluster.ClusterName)
+
+	requirements, err = GetRequirementsFromGit(gitter, repoDir, "v1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "pinned", requirements.Cluster.ClusterName)
+
+	_, err = GetRequirementsFromGit(gitter, repoDir, "does-not-exist")
+	assert.Error(t, err)
+}
+
+// cloneRecordingGitter records the directories cloned into, failing the clones if cloneErr is set
+type cloneRecordingGitter struct {
+	gits.Gitter
+	dirs        []string
+	shallowDirs []string
+	cloneErr    error
+}
+
+func (g *cloneRecordingGitter) Clone(url string, dir string) error {
+	g.dirs = append(g.dirs, dir)
+	if g.cloneErr != nil {
+		return g.cloneErr
+	}
+	return g.Gitter.Clone(url, dir)
+}
+
+func (g *cloneRecordingGitter) ShallowClone(dir string, url string, commitish string, pullRequest string) error {
+	g.shallowDirs = append(g.shallowDirs, dir)
+	if g.cloneErr != nil {
+		return g.cloneErr
+	}
+	return g.Gitter.ShallowClone(dir, url, commitish, pullRequest)
+}
+
+func (g *cloneRecordingGitter) FetchBranchShallow(dir string, repo string, refspec ...string) error {
+	g.shallowDirs = append(g.shallowDirs, dir)
+	if g.cloneErr != nil {
+		return g.cloneErr
+	}
+	return g.Gitter.FetchBranchShallow(dir, repo, refspec...)
+}
+
+func TestGetRequirementsFromGitRemovesClone(t *testing.T) {
+	repoDir, err := ioutil.TempDir("", "test-remote-requirements-")
+	require.NoError(t, err)
+	defer os.RemoveAll(repoDir)
+
+	gitter := &cloneRecordingGitter{Gitter: gits.NewGitCLI()}
+	err = gitter.Init(repoDir)
+	require.NoError(t, err)
+	err = config.NewRequirementsConfig().SaveConfig(filepath.Join(repoDir, config.RequirementsConfigFileName))
+	require.NoError(t, err)
+	err = gitter.Add(repoDir, config.RequirementsConfigFileName)
+	require.NoError(t, err)
+	err = gitter.CommitDir(repoDir, "requirements")
+	require.NoError(t, err)
+
+	_, err = GetRequirementsFromGit(gitter, repoDir, "")
+	require.NoError(t, err)
+	_, err = GetRequirementsFromGit(gitter, repoDir, "does-not-exist")
+	require.Error(t, err)
+	gitter.cloneErr = fmt.Errorf("clone failed")
+	_, err = GetRequirementsFromGit(gitter, repoDir, "")
+	require.Error(t, err)
+
+	require.Len(t, gitter.shallowDirs, 3)
+	for _, dir := range gitter.shallowDirs {
+		exists, err := util.DirExists(dir)
+		require.NoError(t, err)
+		assert.False(t, exists, "the clone directory %s should have been removed", dir)
+	}
+}
+
+func TestGetRequirementsFromGitShallowClones(t *testing.T) {
+	repoDir, err := ioutil.TempDir("", "test-remote-requirements-")
+	require.NoError(t, err)
+	defer os.RemoveAll(repoDir)
+
+	gitter := &cloneRecordingGitter{Gitter: gits.NewGitCLI()}
+	err = gitter.Init(repoDir)
+	require.NoError(t, err)
+	requirements := config.NewRequirementsConfig()
+	requirements.Cluster.ClusterName = "shallow"
+	err = requirements.SaveConfig(filepath.Join(repoDir, config.RequirementsConfigFileName))
+	require.NoError(t, err)
+	err = gitter.Add(repoDir, config.RequirementsConfigFileName)
+	require.NoError(t, err)
+	err = gitter.CommitDir(repoDir, "requirements")
+	require.NoError(t, err)
+	branch, err := gitter.Branch(repoDir)
+	require.NoError(t, err)
+
+	requirements, err = GetRequirementsFromGit(gitter, repoDir, branch)
+	require.NoError(t, err)
+	assert.Equal(t, "shallow", requirements.Cluster.ClusterName)
+	assert.Len(t, gitter.shallowDirs, 1, "a shallow clone should be attempted")
+	assert.Empty(t, gitter.dirs, "a full clone should not be needed")
+}
+
+func TestGetRequirementsFromGitShallowClonesDefaultBranch(t *testing.T) {
+	repoDir, err := ioutil.TempDir("", "test-remote-requirements-")
+	require.NoError(t, err)
+	defer os.RemoveAll(repoDir)
+
+	gitter := &cloneRecordingGitter{Gitter: gits.NewGitCLI()}
+	err = gitter.Init(repoDir)
+	require.NoError(t, err)
+	commitRequirements := func(clusterName string) {
+		requirements := config.NewRequirementsConfig()
+		requirements.Cluster.ClusterName = clusterName
+		err := requirements.SaveConfig(filepath.Join(repoDir, config.RequirementsConfigFileName))
+		require.NoError(t, err)
+		err = gitter.Add(repoDir, config.RequirementsConfigFileName)
+		require.NoError(t, err)
+		err = gitter.CommitDir(repoDir, "requirements for "+clusterName)
+		require.NoError(t, err)
+	}
+
+	// the default branch is trunk, the initial branch such as master is stale
+	commitRequirements("stale")
+	branch, err := gitter.Branch(repoDir)
+	require.NoError(t, err)
+	err = gitter.CreateBranch(repoDir, "trunk")
+	require.NoError(t, err)
+	err = gitter.Checkout(repoDir, "trunk")
+	require.NoError(t, err)
+	commitRequirements("default-branch")
+
+	requirements, err := GetRequirementsFromGit(gitter, repoDir, "")
+	require.NoError(t, err)
+	assert.Equal(t, "default-branch", requirements.Cluster.ClusterName)
+	assert.Len(t, gitter.shallowDirs, 1, "a shallow clone should be attempted")
+	assert.Empty(t, gitter.dirs, "a full clone should not be needed")
+
+	// a repository with only the default branch
+	err = gitter.DeleteLocalBranch(repoDir, branch)
+	require.NoError(t, err)
+	requirements, err = GetRequirementsFromGit(gitter, repoDir, "")
+	require.NoError(t, err)
+	assert.Equal(t, "default-branch", requirements.Cluster.ClusterName)
+	assert.Empty(t, gitter.dirs, "a full clone should not be needed")
+}
+
+func TestGetRequirementsForEnvironmentMemoizes(t *testing.T) {
+	gitter := &cloneRecordingGitter{Gitter: gits.NewGitCLI()}
+	createRepo := func(clusterName string) string {
+		repoDir, err := ioutil.TempDir("", "test-remote-requirements-")
+		require.NoError(t, err)
+		err = gitter.Init(repoDir)
+		require.NoError(t, err)
+		requirements := config.NewRequirementsConfig()
+		requirements.Cluster.ClusterName = clusterName
+		err = requirements.SaveConfig(filepath.Join(repoDir, config.RequirementsConfigFileName))
+		require.NoError(t, err)
+		err = gitter.Add(repoDir, config.RequirementsConfigFileName)
+		require.NoError(t, err)
+		err = gitter.CommitDir(repoDir, "requirements")
+		require.NoError(t, err)
+		return repoDir
+	}
+	sharedRepo := createRepo("shared")
+	defer os.RemoveAll(sharedRepo)
+	otherRepo := createRepo("other")
+	defer os.RemoveAll(otherRepo)
+
+	teamSettingsRequirements := config.NewRequirementsConfig()
+	teamSettingsRequirements.Cluster.ClusterName = "team-settings"
+	data, err := yaml.Marshal(teamSettingsRequirements)
+	require.NoError(t, err)
+
+	environment := func(name string, gitURL string) *v1.Environment {
+		env := kube.NewPermanentEnvironmentWithGit(name, gitURL)
+		env.Spec.Source.Ref = ""
+		env.Spec.RemoteCluster = true
+		return env
+	}
+	withTeamSettings := environment("team-settings", sharedRepo)
+	withTeamSettings.Spec.TeamSettings.BootRequirements = string(data)
+	envs := []*v1.Environment{
+		environment("staging", sharedRepo),
+		environment("production", sharedRepo),
+		environment("other", otherRepo),
+		withTeamSettings,
+	}
+	expected := []string{"shared", "shared", "other", "team-settings"}
+
+	memo := NewRequirementsMemo()
+	for i := 0; i < 2; i++ {
+		for j, env := range envs {
+			requirements, err := GetRequirementsForEnvironment(gitter, env, memo, nil)
+			require.NoError(t, err)
+			assert.Equal(t, expected[j], requirements.Cluster.ClusterName, "requirements of environment %s", env.Name)
+		}
+	}
+	assert.Len(t, gitter.shallowDirs, 2, "the repositories should be cloned once per URL")
+}
+
+func TestGetRequirementsForEnvironmentWithResolver(t *testing.T) {
+	resolved := []string{}
+	resolver := RequirementsResolverFunc(func(env *v1.Environment) (*config.RequirementsConfig, error) {
+		resolved = append(resolved, env.Name)
+		requirements := config.NewRequirementsConfig()
+		requirements.Cluster.ClusterName = env.Name + "-cluster"
+		return requirements, nil
+	})
+
+	staging := kube.NewPermanentEnvironmentWithGit("staging", "https://github.com/my-org/environment-staging.git")
+	production := kube.NewPermanentEnvironmentWithGit("production", "https://github.com/my-org/environment-production.git")
+
+	memo := NewRequirementsMemo()
+	for i := 0; i < 2; i++ {
+		requirements, err := GetRequirementsForEnvironment(nil, staging, memo, resolver)
+		require.NoError(t, err)
+		assert.Equal(t, "staging-cluster", requirements.Cluster.ClusterName)
+
+		requirements, err = GetRequirementsForEnvironment(nil, production, memo, resolver)
+		require.NoError(t, err)
+		assert.Equal(t, "production-cluster", requirements.Cluster.ClusterName)
+	}
+	assert.Equal(t, []string{"staging", "production"}, resolved, "the requirements should be resolved once per environment repository")
+}
@@ -0,0 +1,130 @@
+package applications
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/gits"
+	"github.com/jenkins-x/jx/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// RequirementsCache caches the clones of the git repositories the requirements of remote environments are loaded
+// from, keyed by the git URL and ref. A clone is reused, after fetching and resetting it to the latest version of the
+// ref, until it is older than the TTL. Different repositories are cloned concurrently. The clones are kept until
+// Cleanup is called
+type RequirementsCache struct {
+	TTL time.Duration
+
+	lock    sync.Mutex
+	entries map[string]*requirementsCacheEntry
+	now     func() time.Time
+}
+
+// requirementsCacheEntry a cached clone of a repository, the lock is held while the repository is cloned or updated
+type requirementsCacheEntry struct {
+	lock   sync.Mutex
+	dir    string
+	cloned time.Time
+}
+
+// NewRequirementsCache creates a cache which reuses clones for the given TTL
+func NewRequirementsCache(ttl time.Duration) *RequirementsCache {
+	return &RequirementsCache{
+		TTL:     ttl,
+		entries: map[string]*requirementsCacheEntry{},
+		now:     time.Now,
+	}
+}
+
+// GetRequirementsFromGit loads the requirements in the root directory of the git repository like
+// GetRequirementsFromGit, reusing a previous clone of the same URL and ref if it has not expired
+func (c *RequirementsCache) GetRequirementsFromGit(gitter gits.Gitter, gitURL string, ref string) (*config.RequirementsConfig, error) {
+	if gitURL == "" {
+		return nil, fmt.Errorf("no git URL to load the requirements from")
+	}
+	key := gitURL + "#" + ref
+	entry := c.entry(key)
+	entry.lock.Lock()
+	defer entry.lock.Unlock()
+
+	if entry.dir != "" && c.now().Sub(entry.cloned) < c.TTL {
+		err := updateRequirementsRepository(gitter, ref, entry.dir)
+		if err == nil {
+			return config.LoadRequirementsConfigFile(filepath.Join(entry.dir, config.RequirementsConfigFileName))
+		}
+		log.Logger().Debugf("failed to update the clone of %s in %s, cloning it again: %s", key, entry.dir, err)
+	}
+	entry.remove()
+
+	dir, err := ioutil.TempDir("", "jx-requirements-")
+	if err != nil {
+		return nil, errors.Wrap(err, "creating a temporary directory")
+	}
+	err = cloneRequirementsRepository(gitter, gitURL, ref, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	entry.dir = dir
+	entry.cloned = c.now()
+	return config.LoadRequirementsConfigFile(filepath.Join(dir, config.RequirementsConfigFileName))
+}
+
+// Cleanup removes all the cached clones, waiting for any clones in progress
+func (c *RequirementsCache) Cleanup() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for key, entry := range c.entries {
+		entry.lock.Lock()
+		entry.remove()
+		entry.lock.Unlock()
+		delete(c.entries, key)
+	}
+}
+
+// entry returns the entry of the key, creating it if there is none, only holding the lock of the cache for the lookup
+// so that the repositories of different keys can be cloned concurrently
+func (c *RequirementsCache) entry(key string) *requirementsCacheEntry {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry := c.entries[key]
+	if entry == nil {
+		entry = &requirementsCacheEntry{}
+		c.entries[key] = entry
+	}
+	return entry
+}
+
+// remove removes the clone of the entry if there is one, the lock of the entry must be held
+func (e *requirementsCacheEntry) remove() {
+	if e.dir == "" {
+		return
+	}
+	err := os.RemoveAll(e.dir)
+	if err != nil {
+		log.Logger().Warnf("failed to remove the clone %s: %s", e.dir, err)
+	}
+	e.dir = ""
+}
+
+// updateRequirementsRepository fetches the latest version of the ref, or of the default branch if there is no ref, and
+// resets the clone to it
+func updateRequirementsRepository(gitter gits.Gitter, ref string, dir string) error {
+	refspec := ref
+	if refspec == "" {
+		refspec = "HEAD"
+	}
+	err := gitter.FetchBranch(dir, "origin", refspec)
+	if err != nil {
+		return errors.Wrapf(err, "fetching %s in %s", refspec, dir)
+	}
+	return gitter.Reset(dir, "FETCH_HEAD", true)
+}
@@ -0,0 +1,135 @@
+// +build unit
+
+package applications
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/gits"
+	"github.com/jenkins-x/jx/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequirementsCacheReusesClone(t *testing.T) {
+	repoDir, err := ioutil.TempDir("", "test-requirements-cache-")
+	require.NoError(t, err)
+	defer os.RemoveAll(repoDir)
+
+	gitter := &cloneRecordingGitter{Gitter: gits.NewGitCLI()}
+	err = gitter.Init(repoDir)
+	require.NoError(t, err)
+	commitRequirements := func(clusterName string) {
+		requirements := config.NewRequirementsConfig()
+		requirements.Cluster.ClusterName = clusterName
+		err := requirements.SaveConfig(filepath.Join(repoDir, config.RequirementsConfigFileName))
+		require.NoError(t, err)
+		err = gitter.Add(repoDir, config.RequirementsConfigFileName)
+		require.NoError(t, err)
+		err = gitter.CommitDir(repoDir, "requirements for "+clusterName)
+		require.NoError(t, err)
+	}
+	commitRequirements("first")
+
+	now := time.Now()
+	cache := NewRequirementsCache(time.Minute)
+	cache.now = func() time.Time {
+		return now
+	}
+	defer cache.Cleanup()
+
+	requirements, err := cache.GetRequirementsFromGit(gitter, repoDir, "")
+	require.NoError(t, err)
+	assert.Equal(t, "first", requirements.Cluster.ClusterName)
+
+	// the second call within the TTL fetches the latest changes rather than cloning again
+	commitRequirements("second")
+	requirements, err = cache.GetRequirementsFromGit(gitter, repoDir, "")
+	require.NoError(t, err)
+	assert.Equal(t, "second", requirements.Cluster.ClusterName)
+	assert.Len(t, gitter.shallowDirs, 1)
+
+	// once the TTL has expired the repository is cloned again
+	now = now.Add(2 * time.Minute)
+	requirements, err = cache.GetRequirementsFromGit(gitter, repoDir, "")
+	require.NoError(t, err)
+	assert.Equal(t, "second", requirements.Cluster.ClusterName)
+	require.Len(t, gitter.shallowDirs, 2)
+
+	exists, err := util.DirExists(gitter.shallowDirs[0])
+	require.NoError(t, err)
+	assert.False(t, exists, "the expired clone %s should have been removed", gitter.shallowDirs[0])
+
+	cache.Cleanup()
+	exists, err = util.DirExists(gitter.shallowDirs[1])
+	require.NoError(t, err)
+	assert.False(t, exists, "the clone %s should have been removed", gitter.shallowDirs[1])
+}
+
+// barrierGitter only shallow fetches once all the expected clones have started, so that it times out if the clones
+// are serialized
+type barrierGitter struct {
+	gits.Gitter
+	started sync.WaitGroup
+}
+
+func (g *barrierGitter) FetchBranchShallow(dir string, repo string, refspec ...string) error {
+	g.started.Done()
+	done := make(chan struct{})
+	go func() {
+		g.started.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		return fmt.Errorf("timed out waiting for the other clones to start while cloning into %s", dir)
+	}
+	return g.Gitter.FetchBranchShallow(dir, repo, refspec...)
+}
+
+func TestRequirementsCacheClonesConcurrently(t *testing.T) {
+	gitter := &barrierGitter{Gitter: gits.NewGitCLI()}
+	repoDirs := []string{}
+	for _, clusterName := range []string{"first", "second"} {
+		repoDir, err := ioutil.TempDir("", "test-requirements-cache-")
+		require.NoError(t, err)
+		defer os.RemoveAll(repoDir)
+		err = gitter.Init(repoDir)
+		require.NoError(t, err)
+		requirements := config.NewRequirementsConfig()
+		requirements.Cluster.ClusterName = clusterName
+		err = requirements.SaveConfig(filepath.Join(repoDir, config.RequirementsConfigFileName))
+		require.NoError(t, err)
+		err = gitter.Add(repoDir, config.RequirementsConfigFileName)
+		require.NoError(t, err)
+		err = gitter.CommitDir(repoDir, "requirements for "+clusterName)
+		require.NoError(t, err)
+		repoDirs = append(repoDirs, repoDir)
+	}
+
+	cache := NewRequirementsCache(time.Minute)
+	defer cache.Cleanup()
+
+	gitter.started.Add(len(repoDirs))
+	errs := make([]error, len(repoDirs))
+	var wg sync.WaitGroup
+	for i := range repoDirs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = cache.GetRequirementsFromGit(gitter, repoDirs[i], "")
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		assert.NoError(t, err, "loading the requirements of %s", repoDirs[i])
+	}
+}
@@ -0,0 +1,26 @@
+package amazon
+
+import (
+	"strings"
+)
+
+// awsDNSSuffixes the DNS suffixes of the host names of AWS resources in the commercial, China, GovCloud and isolated
+// partitions. GovCloud host names use the commercial suffix with a us-gov region
+var awsDNSSuffixes = []string{
+	".amazonaws.com",
+	".amazonaws.com.cn",
+	".c2s.ic.gov",
+	".sc2s.sgov.gov",
+}
+
+// IsELBHostname returns true if the host name is the host name of an AWS load balancer, in which case it should be
+// used as is rather than as a nip.io domain
+func IsELBHostname(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, suffix := range awsDNSSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
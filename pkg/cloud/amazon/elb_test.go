@@ -0,0 +1,26 @@
+// +build unit
+
+package amazon_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/cloud/amazon"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsELBHostname(t *testing.T) {
+	t.Parallel()
+
+	for host, expected := range map[string]bool{
+		"a1b2c3-123456789.us-east-1.elb.amazonaws.com":              true,
+		"a1b2c3-123456789.elb.cn-north-1.amazonaws.com.cn":          true,
+		"internal-a1b2c3-123456789.us-gov-west-1.elb.amazonaws.com": true,
+		"a1b2c3-123456789.us-iso-east-1.elb.c2s.ic.gov":             true,
+		"A1B2C3-123456789.US-EAST-1.ELB.AMAZONAWS.COM.":             true,
+		"ingress.example.com":                                       false,
+		"amazonaws.com.example.com":                                 false,
+	} {
+		assert.Equal(t, expected, amazon.IsELBHostname(host), "IsELBHostname(%s)", host)
+	}
+}
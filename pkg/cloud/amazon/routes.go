@@ -8,24 +8,27 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
 	"github.com/jenkins-x/jx/pkg/log"
 	"github.com/jenkins-x/jx/pkg/util"
 	"k8s.io/apimachinery/pkg/util/uuid"
 )
 
 // RegisterAwsCustomDomain registers a wildcard ALIAS for the custom domain
-// to point at the given ELB host name
-func RegisterAwsCustomDomain(customDomain string, elbAddress string) error {
+// to point at the given ELB host name, returning whether the record was changed
+func RegisterAwsCustomDomain(customDomain string, elbAddress string) (bool, error) {
 	sess, err := session.NewAwsSessionWithoutOptions()
 	if err != nil {
-		return err
+		return false, err
 	}
-	svc := route53.New(sess)
+	return registerCustomDomain(route53.New(sess), customDomain, elbAddress)
+}
 
+func registerCustomDomain(svc route53iface.Route53API, customDomain string, elbAddress string) (bool, error) {
 	// find the hosted zone for the domain name
 	var hostedZoneId *string
 	listZonesInput := &route53.ListHostedZonesInput{}
-	err = svc.ListHostedZonesPages(listZonesInput, func(page *route53.ListHostedZonesOutput, hasNext bool) bool {
+	err := svc.ListHostedZonesPages(listZonesInput, func(page *route53.ListHostedZonesOutput, hasNext bool) bool {
 		if page != nil {
 			customDomainParts := strings.Split(customDomain, ".")
 			for _, r := range page.HostedZones {
@@ -39,7 +42,7 @@ func RegisterAwsCustomDomain(customDomain string, elbAddress string) error {
 		return true
 	})
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	if hostedZoneId == nil {
@@ -51,15 +54,15 @@ func RegisterAwsCustomDomain(customDomain string, elbAddress string) error {
 		}
 		results, err := svc.CreateHostedZone(createInput)
 		if err != nil {
-			return err
+			return false, err
 		}
 		if results.HostedZone == nil {
-			return fmt.Errorf("No HostedZone created for name %s!", customDomain)
+			return false, fmt.Errorf("No HostedZone created for name %s!", customDomain)
 		}
 
 		hostedZoneId = results.HostedZone.Id
 		if hostedZoneId == nil {
-			return fmt.Errorf("No HostedZone ID created for name %s!", customDomain)
+			return false, fmt.Errorf("No HostedZone ID created for name %s!", customDomain)
 		}
 	}
 
@@ -68,6 +71,15 @@ func RegisterAwsCustomDomain(customDomain string, elbAddress string) error {
 	recordType := "CNAME"
 	wildcard := "*." + customDomain
 	info := util.ColorInfo
+
+	upToDate, err := hasRecord(svc, hostedZoneId, wildcard, recordType, elbAddress)
+	if err != nil {
+		return false, err
+	}
+	if upToDate {
+		log.Logger().Infof("DNS %s record %s in HostedZone %s already points to %s", info(recordType), info(wildcard), info(*hostedZoneId), info(elbAddress))
+		return false, nil
+	}
 	log.Logger().Infof("About to insert/update DNS %s record into HostedZone %s with wildcard %s pointing to %s", info(recordType), info(*hostedZoneId), info(wildcard), info(elbAddress))
 
 	changeInput := &route53.ChangeResourceRecordSetsInput{
@@ -92,8 +104,33 @@ func RegisterAwsCustomDomain(customDomain string, elbAddress string) error {
 	}
 	_, err = svc.ChangeResourceRecordSets(changeInput)
 	if err != nil {
-		return fmt.Errorf("Failed to update record for hostedZoneID %s: %s", *hostedZoneId, err)
+		return false, fmt.Errorf("Failed to update record for hostedZoneID %s: %s", *hostedZoneId, err)
 	}
 	log.Logger().Infof("Updated HostZone ID %s successfully", info(*hostedZoneId))
-	return nil
+	return true, nil
+}
+
+// hasRecord returns true if the hosted zone already contains a record with the given name and type whose only
+// value is the given one
+func hasRecord(svc route53iface.Route53API, hostedZoneId *string, name string, recordType string, value string) (bool, error) {
+	output, err := svc.ListResourceRecordSets(&route53.ListResourceRecordSetsInput{
+		HostedZoneId:    hostedZoneId,
+		StartRecordName: aws.String(name),
+		StartRecordType: aws.String(recordType),
+		MaxItems:        aws.String("1"),
+	})
+	if err != nil {
+		return false, fmt.Errorf("Failed to list records for hostedZoneID %s: %s", *hostedZoneId, err)
+	}
+	for _, r := range output.ResourceRecordSets {
+		if r == nil || r.Name == nil || r.Type == nil || len(r.ResourceRecords) != 1 || r.ResourceRecords[0].Value == nil {
+			continue
+		}
+		// Route 53 returns fully qualified names with the wildcard escaped
+		recordName := strings.TrimSuffix(strings.Replace(*r.Name, "\\052", "*", 1), ".")
+		if recordName == name && *r.Type == recordType && *r.ResourceRecords[0].Value == value {
+			return true, nil
+		}
+	}
+	return false, nil
 }
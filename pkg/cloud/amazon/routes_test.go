@@ -0,0 +1,82 @@
+// +build unit
+
+package amazon
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockedRoute53 struct {
+	route53iface.Route53API
+	ResourceRecordSets []*route53.ResourceRecordSet
+	Changes            []*route53.ChangeResourceRecordSetsInput
+}
+
+func (m *mockedRoute53) ListHostedZonesPages(input *route53.ListHostedZonesInput, fn func(*route53.ListHostedZonesOutput, bool) bool) error {
+	fn(&route53.ListHostedZonesOutput{
+		HostedZones: []*route53.HostedZone{
+			{
+				Id:   aws.String("/hostedzone/Z1234"),
+				Name: aws.String("example.com."),
+			},
+		},
+	}, false)
+	return nil
+}
+
+func (m *mockedRoute53) ListResourceRecordSets(input *route53.ListResourceRecordSetsInput) (*route53.ListResourceRecordSetsOutput, error) {
+	return &route53.ListResourceRecordSetsOutput{
+		ResourceRecordSets: m.ResourceRecordSets,
+	}, nil
+}
+
+func (m *mockedRoute53) ChangeResourceRecordSets(input *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+	m.Changes = append(m.Changes, input)
+	return &route53.ChangeResourceRecordSetsOutput{}, nil
+}
+
+func wildcardRecord(value string) *route53.ResourceRecordSet {
+	return &route53.ResourceRecordSet{
+		Name: aws.String("\\052.example.com."),
+		Type: aws.String("CNAME"),
+		TTL:  aws.Int64(300),
+		ResourceRecords: []*route53.ResourceRecord{
+			{
+				Value: aws.String(value),
+			},
+		},
+	}
+}
+
+func TestRegisterCustomDomainWithExistingRecord(t *testing.T) {
+	t.Parallel()
+
+	svc := &mockedRoute53{
+		ResourceRecordSets: []*route53.ResourceRecordSet{wildcardRecord("my-elb.amazonaws.com")},
+	}
+	changed, err := registerCustomDomain(svc, "example.com", "my-elb.amazonaws.com")
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.Empty(t, svc.Changes)
+}
+
+func TestRegisterCustomDomainWithChangedRecord(t *testing.T) {
+	t.Parallel()
+
+	svc := &mockedRoute53{
+		ResourceRecordSets: []*route53.ResourceRecordSet{wildcardRecord("old-elb.amazonaws.com")},
+	}
+	changed, err := registerCustomDomain(svc, "example.com", "my-elb.amazonaws.com")
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	if assert.Len(t, svc.Changes, 1) {
+		record := svc.Changes[0].ChangeBatch.Changes[0].ResourceRecordSet
+		assert.Equal(t, "*.example.com", *record.Name)
+		assert.Equal(t, "my-elb.amazonaws.com", *record.ResourceRecords[0].Value)
+	}
+}
@@ -1,6 +1,7 @@
 package cloud
 
 import (
+	"net/url"
 	"sort"
 	"strings"
 )
@@ -18,10 +19,18 @@ const (
 	ICP        = "icp"
 	JX_INFRA   = "jx-infra"
 	ALIBABA    = "alibaba"
+	RKE        = "rke"
+	K3S        = "k3s"
+	MINIKUBE   = "minikube"
+	HCLOUD     = "hcloud"
+	CIVO       = "civo"
+
+	// MINISHIFT a local OpenShift cluster, creating one is no longer supported but existing clusters are still recognized
+	MINISHIFT = "minishift"
 )
 
 // KubernetesProviders list of all available Kubernetes providers
-var KubernetesProviders = []string{GKE, OKE, AKS, AWS, EKS, KUBERNETES, IKS, OPENSHIFT, JX_INFRA, PKS, ICP, ALIBABA}
+var KubernetesProviders = []string{GKE, OKE, AKS, AWS, EKS, KUBERNETES, IKS, OPENSHIFT, JX_INFRA, PKS, ICP, ALIBABA, RKE, K3S, MINIKUBE, HCLOUD, CIVO}
 
 // KubernetesProviderOptions returns all the Kubernetes providers as a string
 func KubernetesProviderOptions() string {
@@ -30,3 +39,102 @@ func KubernetesProviderOptions() string {
 	sort.Strings(values)
 	return strings.Join(values, ", ")
 }
+
+// IsRancher returns true if the provider is a Rancher managed Kubernetes distribution
+func IsRancher(provider string) bool {
+	return provider == RKE || provider == K3S
+}
+
+// IsOpenShift returns true if the provider is an OpenShift cluster, which uses Routes and security context constraints
+func IsOpenShift(provider string) bool {
+	return provider == OPENSHIFT || provider == MINISHIFT
+}
+
+// nodePortProviders the providers whose ingress controller Service is exposed via a NodePort by default as there is
+// usually no cloud load balancer
+var nodePortProviders = map[string]bool{
+	KUBERNETES: true,
+	ICP:        true,
+	K3S:        true,
+	RKE:        true,
+}
+
+// UsesNodePortByDefault returns true if the ingress controller Service of the provider is accessed via a NodePort
+// on the nodes by default rather than a LoadBalancer
+func UsesNodePortByDefault(provider string) bool {
+	return nodePortProviders[provider]
+}
+
+// providerAliases the alternative names of the Kubernetes providers, e.g. the name of the cloud rather than of its
+// Kubernetes service
+var providerAliases = map[string]string{
+	"alicloud": ALIBABA,
+	"aliyun":   ALIBABA,
+	"amazon":   AWS,
+	"azure":    AKS,
+	"gcp":      GKE,
+	"google":   GKE,
+	"hetzner":  HCLOUD,
+	"ibm":      IKS,
+	"k8s":      KUBERNETES,
+	"oci":      OKE,
+	"oracle":   OKE,
+}
+
+// ResolveProviderAlias normalizes the provider name, returning the Kubernetes provider if the name is an alias of one
+func ResolveProviderAlias(provider string) string {
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	if alias, ok := providerAliases[provider]; ok {
+		return alias
+	}
+	return provider
+}
+
+// serverHostSuffixes the suffixes of the host names of the Kubernetes API servers of the managed Kubernetes services
+var serverHostSuffixes = []struct {
+	suffix   string
+	provider string
+}{
+	{".gke.goog", GKE},
+	{".eks.amazonaws.com", EKS},
+	{".azmk8s.io", AKS},
+}
+
+// ProviderFromServerURL returns the Kubernetes provider inferred from the host name of the Kubernetes API server URL,
+// or an empty string if the provider cannot be inferred
+func ProviderFromServerURL(serverURL string) string {
+	host := serverURL
+	u, err := url.Parse(serverURL)
+	if err == nil && u.Host != "" {
+		host = u.Hostname()
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, s := range serverHostSuffixes {
+		if strings.HasSuffix(host, s.suffix) {
+			return s.provider
+		}
+	}
+	return ""
+}
+
+// ingressService the namespace and name of the Service of an ingress controller
+type ingressService struct {
+	namespace string
+	service   string
+}
+
+// defaultIngressServices the Services of the ingress controllers used by default on the providers which do not use the
+// nginx ingress controller installed by Jenkins X into its default namespace
+var defaultIngressServices = map[string]ingressService{
+	// the AWS Load Balancer Controller is installed into kube-system by its Helm chart
+	EKS: {"kube-system", "aws-load-balancer-webhook-service"},
+	// the OpenShift router is managed by the ingress operator
+	OPENSHIFT: {"openshift-ingress", "router-default"},
+}
+
+// DefaultIngressService returns the namespace and name of the Service of the ingress controller used by default on the
+// provider, or false if the provider uses the nginx ingress controller installed by Jenkins X
+func DefaultIngressService(provider string) (string, string, bool) {
+	s, ok := defaultIngressServices[provider]
+	return s.namespace, s.service, ok
+}
@@ -0,0 +1,119 @@
+// +build unit
+
+package cloud_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/cloud"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKubernetesProviderOptionsIncludesRancher(t *testing.T) {
+	t.Parallel()
+
+	options := cloud.KubernetesProviderOptions()
+	assert.Contains(t, options, cloud.RKE)
+	assert.Contains(t, options, cloud.K3S)
+}
+
+func TestKubernetesProviderOptionsIncludesHetznerCloud(t *testing.T) {
+	t.Parallel()
+
+	assert.Contains(t, cloud.KubernetesProviderOptions(), cloud.HCLOUD)
+	assert.Equal(t, cloud.HCLOUD, cloud.ResolveProviderAlias("hetzner"))
+}
+
+func TestKubernetesProviderOptionsIncludesCivo(t *testing.T) {
+	t.Parallel()
+
+	assert.Contains(t, cloud.KubernetesProviderOptions(), cloud.CIVO)
+}
+
+func TestIsRancher(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, cloud.IsRancher(cloud.RKE))
+	assert.True(t, cloud.IsRancher(cloud.K3S))
+	assert.False(t, cloud.IsRancher(cloud.KUBERNETES))
+	assert.False(t, cloud.IsRancher(cloud.GKE))
+}
+
+func TestIsOpenShift(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, cloud.IsOpenShift(cloud.OPENSHIFT))
+	assert.True(t, cloud.IsOpenShift(cloud.MINISHIFT))
+	assert.False(t, cloud.IsOpenShift(cloud.KUBERNETES))
+	assert.False(t, cloud.IsOpenShift(cloud.GKE))
+}
+
+func TestProviderFromServerURL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		serverURL string
+		want      string
+	}{
+		{"https://gke-0123456789abcdef.europe-west1.gke.goog", cloud.GKE},
+		{"https://0123456789ABCDEF.gr7.eu-west-1.eks.amazonaws.com", cloud.EKS},
+		{"https://my-cluster-dns-01234567.hcp.westeurope.azmk8s.io:443", cloud.AKS},
+		{"my-cluster-dns-01234567.hcp.westeurope.azmk8s.io", cloud.AKS},
+		{"https://35.1.2.3", ""},
+		{"https://kubernetes.example.com:6443", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, cloud.ProviderFromServerURL(tt.serverURL), "ProviderFromServerURL(%s)", tt.serverURL)
+	}
+}
+
+func TestResolveProviderAlias(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"gke":     cloud.GKE,
+		" GKE ":   cloud.GKE,
+		"google":  cloud.GKE,
+		"gcp":     cloud.GKE,
+		"Azure":   cloud.AKS,
+		"amazon":  cloud.AWS,
+		"eks":     cloud.EKS,
+		"oracle":  cloud.OKE,
+		"ibm":     cloud.IKS,
+		"aliyun":  cloud.ALIBABA,
+		"k8s":     cloud.KUBERNETES,
+		"unknown": "unknown",
+		"":        "",
+	}
+	for provider, want := range tests {
+		assert.Equal(t, want, cloud.ResolveProviderAlias(provider), "ResolveProviderAlias(%q)", provider)
+	}
+}
+
+func TestDefaultIngressService(t *testing.T) {
+	t.Parallel()
+
+	ns, service, ok := cloud.DefaultIngressService(cloud.OPENSHIFT)
+	assert.True(t, ok)
+	assert.Equal(t, "openshift-ingress", ns)
+	assert.Equal(t, "router-default", service)
+
+	ns, service, ok = cloud.DefaultIngressService(cloud.EKS)
+	assert.True(t, ok)
+	assert.Equal(t, "kube-system", ns)
+	assert.Equal(t, "aws-load-balancer-webhook-service", service)
+
+	_, _, ok = cloud.DefaultIngressService(cloud.GKE)
+	assert.False(t, ok)
+}
+
+func TestUsesNodePortByDefault(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, cloud.UsesNodePortByDefault(cloud.KUBERNETES))
+	assert.True(t, cloud.UsesNodePortByDefault(cloud.RKE))
+	assert.True(t, cloud.UsesNodePortByDefault(cloud.K3S))
+	assert.False(t, cloud.UsesNodePortByDefault(cloud.GKE))
+	assert.False(t, cloud.UsesNodePortByDefault(""))
+}
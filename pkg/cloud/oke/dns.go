@@ -0,0 +1,114 @@
+package oke
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/jenkins-x/jx/pkg/log"
+	"github.com/jenkins-x/jx/pkg/util"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DNSZoneEnvVar the environment variable containing the name or OCID of the OCI DNS zone used for custom domains
+	DNSZoneEnvVar = "OCI_DNS_ZONE"
+	// CLIConfigFileEnvVar the environment variable used by the oci CLI to override the location of its config file
+	CLIConfigFileEnvVar = "OCI_CLI_CONFIG_FILE"
+
+	recordTTL = 300
+)
+
+// OCIRunner an OCI CLI runner to interact with Oracle Cloud Infrastructure
+type OCIRunner struct {
+	Runner util.Commander
+}
+
+type recordItem struct {
+	Domain string `json:"domain"`
+	RData  string `json:"rdata"`
+	RType  string `json:"rtype"`
+	TTL    int    `json:"ttl"`
+}
+
+// NewOCIRunnerWithCommander specifies the command runner for the OCI CLI
+func NewOCIRunnerWithCommander(runner util.Commander) *OCIRunner {
+	return &OCIRunner{
+		Runner: runner,
+	}
+}
+
+// NewOCIRunner returns a new OCIRunner
+func NewOCIRunner() *OCIRunner {
+	runner := &util.Command{}
+	return NewOCIRunnerWithCommander(runner)
+}
+
+// RegisterOCICustomDomain registers a wildcard DNS record for the custom domain pointing at the given LoadBalancer
+// address in the OCI DNS zone configured via $OCI_DNS_ZONE. Registration is skipped if either the zone or the OCI
+// credentials are not configured. It returns whether the record was changed
+func RegisterOCICustomDomain(customDomain string, address string) (bool, error) {
+	zone := os.Getenv(DNSZoneEnvVar)
+	if zone == "" {
+		log.Logger().Warnf("Not registering a DNS record for %s as no OCI DNS zone is configured via $%s", customDomain, DNSZoneEnvVar)
+		return false, nil
+	}
+	if !HasCredentials() {
+		log.Logger().Warnf("Not registering a DNS record for %s as no OCI CLI configuration could be found", customDomain)
+		return false, nil
+	}
+	err := NewOCIRunner().RegisterCustomDomain(zone, customDomain, address)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// HasCredentials returns true if an OCI CLI config file is available to authenticate with
+func HasCredentials() bool {
+	configFile := os.Getenv(CLIConfigFileEnvVar)
+	if configFile == "" {
+		configFile = filepath.Join(util.HomeDir(), ".oci", "config")
+	}
+	exists, err := util.FileExists(configFile)
+	return err == nil && exists
+}
+
+// RegisterCustomDomain creates or updates a wildcard record for the custom domain in the given zone pointing at the
+// LoadBalancer address
+func (r *OCIRunner) RegisterCustomDomain(zone string, customDomain string, address string) error {
+	wildcard := "*." + customDomain
+	recordType := "A"
+	if net.ParseIP(address) == nil {
+		recordType = "CNAME"
+	}
+	items, err := json.Marshal([]recordItem{
+		{
+			Domain: wildcard,
+			RData:  address,
+			RType:  recordType,
+			TTL:    recordTTL,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	info := util.ColorInfo
+	log.Logger().Infof("About to insert/update DNS %s record into OCI zone %s with wildcard %s pointing to %s", info(recordType), info(zone), info(wildcard), info(address))
+
+	_, err = r.ociCLI("dns", "record", "rrset", "update", "--zone-name-or-id", zone, "--domain", wildcard,
+		"--rtype", recordType, "--items", string(items), "--force")
+	if err != nil {
+		return errors.Wrapf(err, "failed to update record %s in OCI zone %s", wildcard, zone)
+	}
+	log.Logger().Infof("Updated OCI zone %s successfully", info(zone))
+	return nil
+}
+
+func (r *OCIRunner) ociCLI(args ...string) (string, error) {
+	r.Runner.SetName("oci")
+	r.Runner.SetArgs(args)
+	return r.Runner.RunWithoutRetry()
+}
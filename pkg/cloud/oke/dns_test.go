@@ -0,0 +1,49 @@
+// +build unit
+
+package oke_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/cloud/oke"
+	mocks "github.com/jenkins-x/jx/pkg/util/mocks"
+	. "github.com/petergtz/pegomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterCustomDomain(t *testing.T) {
+	RegisterMockTestingT(t)
+	runner := mocks.NewMockCommander()
+	When(runner.RunWithoutRetry()).ThenReturn("", nil)
+	ociCLI := oke.NewOCIRunnerWithCommander(runner)
+
+	err := ociCLI.RegisterCustomDomain("my-zone", "example.com", "1.2.3.4")
+	assert.NoError(t, err)
+
+	runner.VerifyWasCalledOnce().SetName("oci")
+	runner.VerifyWasCalledOnce().SetArgs([]string{"dns", "record", "rrset", "update", "--zone-name-or-id", "my-zone",
+		"--domain", "*.example.com", "--rtype", "A",
+		"--items", `[{"domain":"*.example.com","rdata":"1.2.3.4","rtype":"A","ttl":300}]`, "--force"})
+}
+
+func TestRegisterCustomDomainFails(t *testing.T) {
+	RegisterMockTestingT(t)
+	runner := mocks.NewMockCommander()
+	When(runner.RunWithoutRetry()).ThenReturn("", errors.New("zone not found"))
+	ociCLI := oke.NewOCIRunnerWithCommander(runner)
+
+	err := ociCLI.RegisterCustomDomain("my-zone", "example.com", "1.2.3.4")
+	assert.Error(t, err)
+}
+
+func TestRegisterOCICustomDomainSkippedWithoutZone(t *testing.T) {
+	original := os.Getenv(oke.DNSZoneEnvVar)
+	defer os.Setenv(oke.DNSZoneEnvVar, original)
+	os.Unsetenv(oke.DNSZoneEnvVar)
+
+	changed, err := oke.RegisterOCICustomDomain("example.com", "1.2.3.4")
+	assert.NoError(t, err)
+	assert.False(t, changed)
+}
@@ -884,7 +884,7 @@ func (options *InstallOptions) init() error {
 			ecConfig.URLTemplate = options.Flags.ExposeControllerURLTemplate
 			log.Logger().Infof("set exposeController Config URLTemplate %s", ecConfig.URLTemplate)
 		}
-		if isOpenShiftProvider(options.Flags.Provider) {
+		if cloud.IsOpenShift(options.Flags.Provider) {
 			ecConfig.Exposer = "Route"
 		}
 	}
@@ -2682,7 +2682,7 @@ func (options *InstallOptions) saveClusterConfig() error {
 func (options *InstallOptions) configureJenkins(namespace string) error {
 	if !options.Flags.Prow {
 		log.Logger().Info("Configure Jenkins API Token")
-		if isOpenShiftProvider(options.Flags.Provider) {
+		if cloud.IsOpenShift(options.Flags.Provider) {
 			options.CreateJenkinsUserOptions.CommonOptions = options.CommonOptions
 			options.CreateJenkinsUserOptions.Password = options.AdminSecretsService.Flags.DefaultAdminPassword
 			options.CreateJenkinsUserOptions.Username = "jenkins-admin"
@@ -2956,15 +2956,6 @@ func gitOpsModifyEnvironment(dir string, name string, defaultEnvironment *v1.Env
 	return &answer, nil
 }
 
-func isOpenShiftProvider(provider string) bool {
-	switch provider {
-	case cloud.OPENSHIFT:
-		return true
-	default:
-		return false
-	}
-}
-
 func (options *InstallOptions) enableOpenShiftSCC(ns string) error {
 	log.Logger().Infof("Enabling anyuid for the Jenkins service account in namespace %s", ns)
 	err := options.RunCommand("oc", "adm", "policy", "add-scc-to-user", "anyuid", "system:serviceaccount:"+ns+":jenkins")
@@ -3284,7 +3275,7 @@ func (options *InstallOptions) dockerRegistryValue() (string, error) {
 	if options.Flags.Provider == cloud.AWS || options.Flags.Provider == cloud.EKS {
 		return amazon.GetContainerRegistryHost()
 	}
-	if options.Flags.Provider == cloud.OPENSHIFT {
+	if cloud.IsOpenShift(options.Flags.Provider) {
 		return "docker-registry.default.svc:5000", nil
 	}
 	if options.Flags.Provider == cloud.GKE {
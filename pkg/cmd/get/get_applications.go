@@ -19,6 +19,7 @@ import (
 	"github.com/jenkins-x/jx/pkg/cmd/templates"
 	"github.com/jenkins-x/jx/pkg/kube"
 	"github.com/jenkins-x/jx/pkg/log"
+	"github.com/jenkins-x/jx/pkg/util"
 	"k8s.io/api/apps/v1beta1"
 )
 
@@ -31,6 +32,7 @@ type GetApplicationsOptions struct {
 	HideUrl     bool
 	HidePod     bool
 	Previews    bool
+	Output      string
 }
 
 // Applications is a map indexed by the application name then the environment name
@@ -73,6 +75,9 @@ var (
 
 		# List applications just showing the versions (hiding urls and pod counts)
 		jx get applications -u -p
+
+		# List applications as JSON
+		jx get applications -o json
 	`)
 )
 
@@ -99,6 +104,7 @@ func NewCmdGetApplications(commonOpts *opts.CommonOptions) *cobra.Command {
 	cmd.Flags().BoolVarP(&options.Previews, "preview", "w", false, "Show preview environments only")
 	cmd.Flags().StringVarP(&options.Environment, "env", "e", "", "Filter applications in the given environment")
 	cmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "Filter applications in the given namespace")
+	cmd.Flags().StringVarP(&options.Output, "output", "o", "", "The output format such as 'yaml' or 'json'")
 	return cmd
 }
 
@@ -122,12 +128,47 @@ func (o *GetApplicationsOptions) Run() error {
 	if err != nil {
 		return err
 	}
+	if !o.HideUrl || o.Output != "" {
+		list, err = list.ResolveURLs(kubeClient)
+		if err != nil {
+			return errors.Wrap(err, "resolving application URLs")
+		}
+	}
+	if o.Output != "" {
+		return o.renderApplications(kubeClient, list)
+	}
 	table := o.generateTable(kubeClient, list)
 	table.Render()
 
+	undeployed := list.Undeployed()
+	if len(undeployed) > 0 {
+		names := make([]string, 0, len(undeployed))
+		for _, a := range undeployed {
+			names = append(names, a.Name())
+		}
+		log.Logger().Infof("\nThe following applications have not been deployed to any environment: %s", util.ColorInfo(strings.Join(names, ", ")))
+	}
 	return nil
 }
 
+func (o *GetApplicationsOptions) renderApplications(kubeClient kubernetes.Interface, list applications.List) error {
+	var data []byte
+	var err error
+	switch o.Output {
+	case "json":
+		data, err = list.ToJSON(kubeClient)
+	case "yaml":
+		data, err = list.ToYAML(kubeClient)
+	default:
+		return fmt.Errorf("Unsupported output format: %s", o.Output)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "rendering applications as %s", o.Output)
+	}
+	_, err = o.Out.Write(data)
+	return err
+}
+
 func (o *GetApplicationsOptions) generateTable(kubeClient kubernetes.Interface, list applications.List) table.Table {
 	table := o.generateTableHeaders(list)
 
@@ -154,7 +195,7 @@ func (o *GetApplicationsOptions) generateTable(kubeClient kubernetes.Interface,
 							row = append(row, d.Pods())
 						}
 						if !o.HideUrl {
-							row = append(row, d.URL(kubeClient, a))
+							row = append(row, d.URLInEnvironment(kubeClient, a, ae))
 						}
 					}
 				} else {
@@ -446,7 +446,7 @@ func (o *InitOptions) InitIngress() error {
 		return fmt.Errorf("Failed to ensure the ingress namespace %s is created: %s\nIs this an RBAC issue on your cluster?", ingressNamespace, err)
 	}
 
-	if isOpenShiftProvider(o.Flags.Provider) {
+	if cloud.IsOpenShift(o.Flags.Provider) {
 		log.Logger().Info("Not installing ingress as using OpenShift which uses Route and its own mechanism of ingress")
 		return nil
 	}
@@ -555,7 +555,7 @@ controller:
 		log.Logger().Info("existing ingress controller found, no need to install a new one")
 	}
 
-	if o.Flags.Provider != cloud.OPENSHIFT {
+	if !cloud.IsOpenShift(o.Flags.Provider) {
 		if o.Flags.Provider == cloud.OKE {
 			log.Logger().Infof("Note: this loadbalancer will fail to be provisioned if you have insufficient quotas, this can happen easily on a OCI free account")
 		}
@@ -668,12 +668,3 @@ func (o *InitOptions) HelmBinary() string {
 	}
 	return "helm"
 }
-
-func isOpenShiftProvider(provider string) bool {
-	switch provider {
-	case cloud.OPENSHIFT:
-		return true
-	default:
-		return false
-	}
-}
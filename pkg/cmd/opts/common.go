@@ -110,7 +110,10 @@ type CommonOptions struct {
 	Cmd                    *cobra.Command
 	ConfigFile             string
 	Domain                 string
+	DomainOverrideFunc     func(address string, provider string) (string, bool)
 	Err                    io.Writer
+	ExternalIPConfigMap    string
+	ExternalIPConfigMapKey string
 	ExternalJenkinsBaseURL string
 	In                     terminal.FileReader
 	InstallDependencies    bool
@@ -118,16 +121,27 @@ type CommonOptions struct {
 	ModifyEnvironmentFn    ModifyEnvironmentFn
 	NameServers            []string
 	NoBrew                 bool
+	PlanOnly               bool
+	PreferHostname         bool
+	PrivateCluster         bool
 	RemoteCluster          bool
+	RequireExplicitDomain  bool
+	Resolver               Resolver
 	Out                    terminal.FileWriter
 	ServiceAccount         string
 	SkipAuthSecretsMerge   bool
+	SkipDNSRegistration    bool
+	SkipHostnameResolution bool
+	Sleep                  func(time.Duration)
+	StrictExternalIP       bool
+	UseHostnameAsDomain    bool
 	Username               string
 	Verbose                bool
 	NotifyCallback         func(LogLevel, string)
 
 	apiExtensionsClient apiextensionsclientset.Interface
 	certManagerClient   certmngclient.Interface
+	chartMuseumURL      string
 	complianceClient    *client.SonobuoyClient
 	currentNamespace    string
 	devNamespace        string
@@ -866,6 +880,15 @@ func (o *CommonOptions) RetryUntilFatalError(attempts int, sleep time.Duration,
 	return fmt.Errorf("after %d attempts, last error: %s", attempts, err)
 }
 
+// sleep pauses for the duration using the Sleep function if one is configured, e.g. to avoid real delays in tests
+func (o *CommonOptions) sleep(d time.Duration) {
+	if o.Sleep != nil {
+		o.Sleep(d)
+		return
+	}
+	time.Sleep(d)
+}
+
 // RetryQuiet executes a given function call with retry when an error occurs without printing any logs
 func (o *CommonOptions) RetryQuiet(attempts int, sleep time.Duration, call func() error) (err error) {
 	lastMessage := ""
@@ -884,7 +907,7 @@ func (o *CommonOptions) RetryQuiet(attempts int, sleep time.Duration, call func(
 			break
 		}
 
-		time.Sleep(sleep)
+		o.sleep(sleep)
 
 		message := fmt.Sprintf("retrying after error: %s", err)
 		if lastMessage == message {
@@ -1122,6 +1145,7 @@ func (o *CommonOptions) ResetClientsAndNamespaces() {
 	o.jxClient = nil
 	o.currentNamespace = ""
 	o.devNamespace = ""
+	o.chartMuseumURL = ""
 }
 
 // GetIn returns the command inputs writer
@@ -19,6 +19,8 @@ work
 	DefaultIngressNamesapce = "kube-system"
 	// DefaultIngressServiceName default name for ingress controller service and deployment
 	DefaultIngressServiceName = "jxing-nginx-ingress-controller"
+	// DefaultExternalIPConfigMapKey default key of the ConfigMap entry containing the external IP of the ingress controller
+	DefaultExternalIPConfigMapKey = "externalIP"
 
 	// DeployKindKnative for knative serve based deployments
 	DeployKindKnative = "knative"
@@ -4,54 +4,317 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jenkins-x/jx/pkg/cloud"
 	"github.com/jenkins-x/jx/pkg/cloud/amazon"
 	"github.com/jenkins-x/jx/pkg/cloud/iks"
+	"github.com/jenkins-x/jx/pkg/cloud/oke"
+	"github.com/jenkins-x/jx/pkg/config"
 	"github.com/jenkins-x/jx/pkg/log"
 	"github.com/jenkins-x/jx/pkg/surveyutils"
 	"github.com/jenkins-x/jx/pkg/util"
+	"github.com/pkg/errors"
 	"gopkg.in/AlecAivazis/survey.v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 )
 
+// DNSRegistrar registers a wildcard DNS record for a custom domain pointing at the ingress address, returning
+// whether the record was changed
+type DNSRegistrar interface {
+	Register(domain, address string) (bool, error)
+}
+
+// DNSRegistrarFunc adapts a plain function into a DNSRegistrar
+type DNSRegistrarFunc func(domain, address string) (bool, error)
+
+// Register registers the domain by invoking the function
+func (f DNSRegistrarFunc) Register(domain, address string) (bool, error) {
+	return f(domain, address)
+}
+
+// Resolver resolves host names into IP addresses
+type Resolver interface {
+	LookupIP(host string) ([]net.IP, error)
+}
+
+// netResolver resolves host names using the local resolver of the net package
+type netResolver struct{}
+
+// LookupIP looks up the host using the local resolver
+func (netResolver) LookupIP(host string) ([]net.IP, error) {
+	return net.LookupIP(host)
+}
+
+// GetResolver returns the Resolver used to resolve host names, defaulting to the net package
+func (o *CommonOptions) GetResolver() Resolver {
+	if o.Resolver == nil {
+		return netResolver{}
+	}
+	return o.Resolver
+}
+
+var (
+	dnsRegistrarsLock sync.RWMutex
+	dnsRegistrars     = map[string]DNSRegistrar{
+		cloud.AWS: DNSRegistrarFunc(amazon.RegisterAwsCustomDomain),
+		cloud.EKS: DNSRegistrarFunc(amazon.RegisterAwsCustomDomain),
+		cloud.OKE: DNSRegistrarFunc(oke.RegisterOCICustomDomain),
+	}
+)
+
+// RegisterDNSRegistrar registers the DNSRegistrar used by GetDomain for the given provider, replacing any existing one
+func RegisterDNSRegistrar(provider string, registrar DNSRegistrar) {
+	dnsRegistrarsLock.Lock()
+	defer dnsRegistrarsLock.Unlock()
+	dnsRegistrars[provider] = registrar
+}
+
+// GetDNSRegistrar returns the DNSRegistrar for the given provider if there is one
+func GetDNSRegistrar(provider string) (DNSRegistrar, bool) {
+	dnsRegistrarsLock.RLock()
+	defer dnsRegistrarsLock.RUnlock()
+	registrar, ok := dnsRegistrars[provider]
+	return registrar, ok
+}
+
+// registerDNS registers the domain with the registrar, logging when the DNS record did not need to change. In plan
+// only mode the record which would be registered is logged instead
+func (o *CommonOptions) registerDNS(registrar DNSRegistrar, domain string, address string) error {
+	if o.PlanOnly {
+		recordType := "A"
+		if net.ParseIP(address) == nil {
+			recordType = "CNAME"
+		}
+		log.Logger().Infof("plan only so not registering the DNS record %s of type %s pointing at %s",
+			util.ColorInfo("*."+domain), util.ColorInfo(recordType), util.ColorInfo(address))
+		return nil
+	}
+	changed, err := registrar.Register(domain, address)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		log.Logger().Infof("DNS already up to date for %s", util.ColorInfo(domain))
+	}
+	return nil
+}
+
+// DomainCandidate a domain which can be used to access the ingress controller along with the address it was derived
+// from. Candidates with a lower Priority are preferred
+type DomainCandidate struct {
+	Domain   string
+	Address  string
+	Source   string
+	Priority int
+}
+
+const (
+	// DomainSourceExternalIP the candidate address was specified explicitly
+	DomainSourceExternalIP = "external-ip"
+	// DomainSourceLoadBalancerIP the candidate address is an IP of the ingress controller LoadBalancer
+	DomainSourceLoadBalancerIP = "load-balancer-ip"
+	// DomainSourceLoadBalancerHostname the candidate address is a host name of the ingress controller LoadBalancer
+	DomainSourceLoadBalancerHostname = "load-balancer-hostname"
+	// DomainSourceConfigMap the candidate address was read from the ExternalIPConfigMap
+	DomainSourceConfigMap = "configmap"
+	// DomainSourceMinikube the candidate address is the minikube IP
+	DomainSourceMinikube = "minikube"
+	// DomainSourceNodeIP the candidate address is the external IP of a node used to access a NodePort service
+	DomainSourceNodeIP = "node-ip"
+	// DomainSourcePodHostIP the candidate address is the public host IP of an ingress controller pod
+	DomainSourcePodHostIP = "pod-host-ip"
+)
+
+// newDomainCandidate creates a candidate for the address using the magic DNS nip.io for IP addresses
+func newDomainCandidate(address string, source string, priority int) DomainCandidate {
+	domain := address
+	if net.ParseIP(address) != nil {
+		domain = fmt.Sprintf("%s.nip.io", address)
+	}
+	return DomainCandidate{
+		Domain:   domain,
+		Address:  address,
+		Source:   source,
+		Priority: priority,
+	}
+}
+
+// GetDomainCandidates returns the domains which can be derived for the ingress controller Service ordered by priority.
+// All the addresses of the LoadBalancer are returned, the other sources such as the ExternalIPConfigMap or the nodes
+// are only consulted if the LoadBalancer does not have an address yet
+func (o *CommonOptions) GetDomainCandidates(client kubernetes.Interface, provider string, ingressNamespace string, ingressService string, externalIP string) ([]DomainCandidate, error) {
+	if externalIP != "" {
+		return []DomainCandidate{newDomainCandidate(externalIP, DomainSourceExternalIP, 0)}, nil
+	}
+	svc, err := client.CoreV1().Services(ingressNamespace).Get(ingressService, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	ipPriority, hostnamePriority := 1, 2
+	if o.PreferHostname {
+		// load balancers using the proxy protocol have to be accessed via their host name
+		ipPriority, hostnamePriority = 2, 1
+	}
+	candidates := []DomainCandidate{}
+	for _, v := range svc.Status.LoadBalancer.Ingress {
+		if v.IP != "" {
+			candidates = append(candidates, newDomainCandidate(v.IP, DomainSourceLoadBalancerIP, ipPriority))
+		}
+		if v.Hostname != "" {
+			candidates = append(candidates, newDomainCandidate(v.Hostname, DomainSourceLoadBalancerHostname, hostnamePriority))
+		}
+	}
+	if len(candidates) == 0 {
+		address, source, err := o.findFallbackIngressAddress(client, svc, provider)
+		if err != nil {
+			return nil, err
+		}
+		if address != "" {
+			candidates = append(candidates, newDomainCandidate(address, source, 3))
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Priority < candidates[j].Priority
+	})
+	return candidates, nil
+}
+
+// findFallbackIngressAddress returns an address of the ingress controller when its Service has no LoadBalancer
+// address along with the source of the address
+func (o *CommonOptions) findFallbackIngressAddress(client kubernetes.Interface, svc *corev1.Service, provider string) (string, string, error) {
+	// some bare metal load balancers such as MetalLB record the assigned address in a ConfigMap
+	if o.ExternalIPConfigMap != "" {
+		address, err := o.findExternalIPFromConfigMap(client, svc.Namespace)
+		if err != nil || address != "" {
+			return address, DomainSourceConfigMap, err
+		}
+	}
+	// with 'minikube tunnel' the LoadBalancer address is preferred, otherwise use the minikube node IP
+	if provider == cloud.MINIKUBE {
+		ip, err := o.GetCommandOutput("", "minikube", "ip")
+		if err != nil {
+			return "", "", errors.Wrap(err, "failed to find the minikube IP address, you may want to run 'minikube tunnel'")
+		}
+		return strings.TrimSpace(ip), DomainSourceMinikube, nil
+	}
+	if svc.Spec.Type == corev1.ServiceTypeNodePort {
+		address, err := FindFirstExternalNodeIP(client)
+		if err != nil || address != "" {
+			return address, DomainSourceNodeIP, err
+		}
+		address, err = findFirstPublicPodHostIP(client, svc)
+		return address, DomainSourcePodHostIP, err
+	}
+	return "", "", nil
+}
+
+// cloudLoadBalancerDomains the domains of the host names generated for load balancers by cloud providers, along with
+// the AWS ELB domains and auto DNS domains, which are not suitable to be used as the domain directly
+var cloudLoadBalancerDomains = []string{
+	".cloudapp.azure.com",
+	".cloudapp.net",
+	".appdomain.cloud",
+}
+
+// IsCloudLoadBalancerHostname returns true if the host name was generated for a load balancer by a cloud provider or
+// is an auto DNS domain
+func IsCloudLoadBalancerHostname(host string) bool {
+	if amazon.IsELBHostname(host) || config.IsAutoDNSDomain(host) {
+		return true
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, suffix := range cloudLoadBalancerDomains {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// useHostnameAsDomain returns true if the Ingress host name should be used as the domain rather than its IP address.
+// Only resolvable host names which are not generated for a cloud load balancer are used, which requires the
+// UseHostnameAsDomain option in batch mode
+func (o *CommonOptions) useHostnameAsDomain(host string) (bool, error) {
+	if IsCloudLoadBalancerHostname(host) {
+		return false, nil
+	}
+	ips, err := o.GetResolver().LookupIP(host)
+	if err != nil || len(ips) == 0 {
+		return false, nil
+	}
+	if o.BatchMode {
+		return o.UseHostnameAsDomain, nil
+	}
+	return util.Confirm(fmt.Sprintf("The Ingress host name %s looks like a custom domain, would you like to use it as the domain?", host), true,
+		"If the host name is a wildcard DNS name you own it can be used as the domain rather than an IP address based nip.io domain", o.GetIOFileHandles())
+}
+
+// ErrLoadBalancerPending is returned by GetDomain when the ingress controller Service is a LoadBalancer which has not
+// been assigned an address yet, so the caller can wait for the address and try again
+var ErrLoadBalancerPending = errors.New("the LoadBalancer of the ingress controller Service has no address yet")
+
+// isLoadBalancerPending returns true if the Service is a LoadBalancer which has no address yet
+func isLoadBalancerPending(client kubernetes.Interface, ns string, name string) bool {
+	svc, err := client.CoreV1().Services(ns).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return false
+	}
+	return svc.Spec.Type == corev1.ServiceTypeLoadBalancer && len(svc.Status.LoadBalancer.Ingress) == 0
+}
+
 // GetDomain returns the domain name, trying to infer it either from various Kubernetes resources or cloud provider. If no domain
 // can be determined, it will prompt to the user for a value.
 func (o *CommonOptions) GetDomain(client kubernetes.Interface, domain string, provider string, ingressNamespace string, ingressService string, externalIP string) (string, error) {
 	surveyOpts := survey.WithStdio(o.In, o.Out, o.Err)
 	address := externalIP
-	if address == "" {
+	if address != "" {
+		err := o.verifyNodePortExternalIP(client, ingressNamespace, ingressService, address)
+		if err != nil {
+			return "", err
+		}
+	} else {
 		info := util.ColorInfo
 		log.Logger().Infof("Waiting to find the external host name of the ingress controller Service in namespace %s with name %s",
 			info(ingressNamespace), info(ingressService))
-		if provider == cloud.KUBERNETES {
+		if provider == cloud.KUBERNETES || cloud.IsRancher(provider) {
 			log.Logger().Infof("If you are installing Jenkins X on premise you may want to use the '--on-premise' flag or specify the '--external-ip' flags. See: %s",
 				info("https://jenkins-x.io/getting-started/install-on-cluster/#installing-jenkins-x-on-premise"))
 		}
-		svc, err := client.CoreV1().Services(ingressNamespace).Get(ingressService, metav1.GetOptions{})
+		if cloud.IsRancher(provider) {
+			log.Logger().Infof("On Rancher clusters a L4 load balancer can be used to provide an external address for the ingress controller Service, otherwise the external IP of a node will be used")
+		}
+		candidates, err := o.GetDomainCandidates(client, provider, ingressNamespace, ingressService, "")
 		if err != nil {
 			return "", err
 		}
-		if svc != nil {
-			for _, v := range svc.Status.LoadBalancer.Ingress {
-				if v.IP != "" {
-					address = v.IP
-				} else if v.Hostname != "" {
-					address = v.Hostname
-				}
-			}
+		if len(candidates) > 0 {
+			address = candidates[0].Address
+		} else if domain == "" && isLoadBalancerPending(client, ingressNamespace, ingressService) {
+			return "", ErrLoadBalancerPending
 		}
 	}
 	defaultDomain := address
 
-	if provider == cloud.AWS || provider == cloud.EKS {
-		if domain != "" {
-			err := amazon.RegisterAwsCustomDomain(domain, address)
-			return domain, err
-		}
+	registrar, hasRegistrar := GetDNSRegistrar(provider)
+	if hasRegistrar && o.SkipDNSRegistration {
+		log.Logger().Debugf("skipping the DNS registration for provider %s as the DNS is managed externally", provider)
+		hasRegistrar = false
+	}
+	if hasRegistrar && domain != "" {
+		err := o.registerDNS(registrar, domain, address)
+		return domain, err
+	}
+
+	if hasRegistrar && (provider == cloud.AWS || provider == cloud.EKS) {
 
 		// if we are booting, we want to use nip.io directly if a domain is not provided
 		// if one is provided, we'll expose it through external-dns
@@ -77,7 +340,7 @@ func (o *CommonOptions) GetDomain(client kubernetes.Interface, domain string, pr
 					}
 					survey.AskOne(prompt, &customDomain, nil, surveyOpts)
 					if customDomain != "" {
-						err := amazon.RegisterAwsCustomDomain(customDomain, address)
+						err := o.registerDNS(registrar, customDomain, address)
 						return customDomain, err
 					}
 				} else {
@@ -93,66 +356,104 @@ func (o *CommonOptions) GetDomain(client kubernetes.Interface, domain string, pr
 				util.ColorInfo(address))
 			return domain, nil
 		}
-		clusterName, err := iks.GetClusterName()
-		clusterRegion, err := iks.GetKubeClusterRegion(client)
-		if err == nil && clusterName != "" && clusterRegion != "" {
-			customDomain := clusterName + "." + clusterRegion + ".containers.appdomain.cloud"
-			log.Logger().Infof("\nIBM Kubernetes Service will use the default cluster domain: ")
-			log.Logger().Infof("%s", util.ColorInfo(customDomain))
-			return customDomain, nil
+		if o.PrivateCluster {
+			// the public cluster domain is not reachable so use the private address of the LoadBalancer
+			if address == "" {
+				return "", fmt.Errorf("no private address was found for the ingress controller Service %s/%s of the private IBM Kubernetes Service cluster, please specify a domain via --domain",
+					ingressNamespace, ingressService)
+			}
+			log.Logger().Infof("\nIBM Kubernetes Service private cluster will use the private ingress address: %s", util.ColorInfo(address))
+		} else {
+			clusterName, err := iks.GetClusterName()
+			clusterRegion, err := iks.GetKubeClusterRegion(client)
+			if err == nil && clusterName != "" && clusterRegion != "" {
+				customDomain := clusterName + "." + clusterRegion + ".containers.appdomain.cloud"
+				log.Logger().Infof("\nIBM Kubernetes Service will use the default cluster domain: ")
+				log.Logger().Infof("%s", util.ColorInfo(customDomain))
+				return customDomain, nil
+			}
+			log.Logger().Infof("ERROR getting IBM Kubernetes Service will use the default cluster domain:")
+			log.Logger().Infof(err.Error())
+		}
+	}
+
+	// allow embedders to derive the domain from the address themselves, e.g. by looking it up in a CMDB
+	if domain == "" && o.DomainOverrideFunc != nil {
+		if overrideDomain, ok := o.DomainOverrideFunc(address, provider); ok {
+			log.Logger().Infof("Using the domain %s for the Ingress address %s", util.ColorInfo(overrideDomain), util.ColorInfo(address))
+			return overrideDomain, nil
 		}
-		log.Logger().Infof("ERROR getting IBM Kubernetes Service will use the default cluster domain:")
-		log.Logger().Infof(err.Error())
 	}
 
 	if address != "" {
 		addNip := true
 		aip := net.ParseIP(address)
-		if aip == nil {
-			log.Logger().Infof("The Ingress address %s is not an IP address. We recommend we try resolve it to a public IP address and use that for the domain to access services externally.",
-				util.ColorInfo(address))
+		if aip == nil && o.SkipHostnameResolution {
+			// DNS lookups may be blocked so lets not wait for the host name to resolve
+			log.Logger().Infof("Using the Ingress host name %s without resolving it", util.ColorInfo(address))
+			addNip = false
+		} else if aip == nil {
+			useHostname, err := o.useHostnameAsDomain(address)
+			if err != nil {
+				return "", err
+			}
+			if useHostname {
+				log.Logger().Infof("Using the Ingress host name %s as the domain", util.ColorInfo(address))
+				defaultDomain = address
+				addNip = false
+			} else if o.PreferHostname {
+				log.Logger().Infof("Using the Ingress host name %s rather than resolving it to an IP address", util.ColorInfo(address))
+				addNip = false
+			} else {
+				log.Logger().Infof("The Ingress address %s is not an IP address. We recommend we try resolve it to a public IP address and use that for the domain to access services externally.",
+					util.ColorInfo(address))
 
-			addressIP := ""
-			resolve := true
-			if !o.BatchMode {
-				answer, err := util.Confirm("Would you like wait and resolve this address to an IP address and use it for the domain?", true,
-					"Should we convert "+address+" to an IP address so we can access resources externally", o.GetIOFileHandles())
-				if err != nil {
-					return "", err
+				addressIP := ""
+				resolve := true
+				if !o.BatchMode {
+					answer, err := util.Confirm("Would you like wait and resolve this address to an IP address and use it for the domain?", true,
+						"Should we convert "+address+" to an IP address so we can access resources externally", o.GetIOFileHandles())
+					if err != nil {
+						return "", err
+					}
+					resolve = answer
 				}
-				resolve = answer
-			}
-			if resolve {
-				log.Logger().Infof("Waiting for %s to be resolvable to an IP address...", util.ColorInfo(address))
-				f := func() error {
-					ips, err := net.LookupIP(address)
-					if err == nil {
-						for _, ip := range ips {
-							t := ip.String()
-							if t != "" && !ip.IsLoopback() {
-								addressIP = t
-								return nil
+				if resolve {
+					log.Logger().Infof("Waiting for %s to be resolvable to an IP address...", util.ColorInfo(address))
+					resolver := o.GetResolver()
+					f := func() error {
+						ips, err := resolver.LookupIP(address)
+						if err == nil {
+							for _, ip := range ips {
+								t := ip.String()
+								if t != "" && !ip.IsLoopback() {
+									addressIP = t
+									return nil
+								}
 							}
 						}
+						return fmt.Errorf("Address cannot be resolved yet %s", address)
 					}
-					return fmt.Errorf("Address cannot be resolved yet %s", address)
+					o.RetryQuiet(5*6, time.Second*10, f)
+				}
+				if addressIP == "" {
+					addNip = false
+					log.Logger().Infof("Still not managed to resolve address %s into an IP address. Please try figure out the domain by hand", address)
+				} else {
+					log.Logger().Infof("%s resolved to IP %s", util.ColorInfo(address), util.ColorInfo(addressIP))
+					address = addressIP
 				}
-				o.RetryQuiet(5*6, time.Second*10, f)
-			}
-			if addressIP == "" {
-				addNip = false
-				log.Logger().Infof("Still not managed to resolve address %s into an IP address. Please try figure out the domain by hand", address)
-			} else {
-				log.Logger().Infof("%s resolved to IP %s", util.ColorInfo(address), util.ColorInfo(addressIP))
-				address = addressIP
 			}
 		}
-		if addNip && !strings.HasSuffix(address, ".amazonaws.com") {
+		if addNip && !amazon.IsELBHostname(address) {
 			defaultDomain = fmt.Sprintf("%s.nip.io", address)
 		}
 	}
 
 	if domain == "" {
+		if o.BatchMode && o.RequireExplicitDomain {
+			return "", fmt.Errorf("no domain was specified and a default domain is not allowed, please specify a domain via --domain")
+		}
 		if o.BatchMode {
 			log.Logger().Infof("No domain flag provided so using default %s to generate Ingress rules", defaultDomain)
 			return defaultDomain, nil
@@ -183,3 +484,178 @@ func (o *CommonOptions) GetDomain(client kubernetes.Interface, domain string, pr
 
 	return domain, nil
 }
+
+// IngressRef references an ingress controller Service a domain should be resolved for, e.g. the internal and external
+// ingress controllers of a split-horizon setup
+type IngressRef struct {
+	// Name the key of the resolved domain, defaults to the namespace and name of the Service
+	Name       string
+	Namespace  string
+	Service    string
+	Domain     string
+	ExternalIP string
+}
+
+// Key returns the key of the domain resolved for the ingress controller Service
+func (r IngressRef) Key() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.Namespace + "/" + r.Service
+}
+
+// GetDomains resolves the domain of each of the ingress controller Services like GetDomain, returning the domains
+// keyed by IngressRef.Key. The Services are resolved concurrently in batch mode, otherwise one at a time so that the
+// prompts do not interleave
+func (o *CommonOptions) GetDomains(client kubernetes.Interface, provider string, services []IngressRef) (map[string]string, error) {
+	domains := make([]string, len(services))
+	errs := make([]error, len(services))
+	resolve := func(i int) {
+		ref := services[i]
+		domain, err := o.GetDomain(client, ref.Domain, provider, ref.Namespace, ref.Service, ref.ExternalIP)
+		if err != nil {
+			errs[i] = errors.Wrapf(err, "resolving the domain of the ingress controller Service %s/%s", ref.Namespace, ref.Service)
+			return
+		}
+		domains[i] = domain
+	}
+
+	if o.BatchMode {
+		var wg sync.WaitGroup
+		for i := range services {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				resolve(i)
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		for i := range services {
+			resolve(i)
+		}
+	}
+
+	err := util.CombineErrors(errs...)
+	if err != nil {
+		return nil, err
+	}
+	answer := map[string]string{}
+	for i, ref := range services {
+		answer[ref.Key()] = domains[i]
+	}
+	return answer, nil
+}
+
+// findExternalIPFromConfigMap returns the external IP of the ingress controller recorded in the ExternalIPConfigMap
+// in the ingress namespace, or an empty string if the ConfigMap or its entry does not exist
+func (o *CommonOptions) findExternalIPFromConfigMap(client kubernetes.Interface, ingressNamespace string) (string, error) {
+	key := o.ExternalIPConfigMapKey
+	if key == "" {
+		key = DefaultExternalIPConfigMapKey
+	}
+	cm, err := client.CoreV1().ConfigMaps(ingressNamespace).Get(o.ExternalIPConfigMap, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Logger().Debugf("no ConfigMap %s found in namespace %s", o.ExternalIPConfigMap, ingressNamespace)
+			return "", nil
+		}
+		return "", errors.Wrapf(err, "getting the ConfigMap %s in namespace %s to find the external IP of the ingress controller", o.ExternalIPConfigMap, ingressNamespace)
+	}
+	return strings.TrimSpace(cm.Data[key]), nil
+}
+
+// verifyNodePortExternalIP checks that the external IP used to access a NodePort ingress controller Service is the
+// address of one of the nodes, as otherwise the domain is probably unreachable. It warns if not, or returns an error
+// if StrictExternalIP is enabled
+func (o *CommonOptions) verifyNodePortExternalIP(client kubernetes.Interface, ingressNamespace string, ingressService string, externalIP string) error {
+	svc, err := client.CoreV1().Services(ingressNamespace).Get(ingressService, metav1.GetOptions{})
+	if err != nil || svc.Spec.Type != corev1.ServiceTypeNodePort {
+		return nil
+	}
+	nodes, err := client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		log.Logger().Debugf("failed to list the nodes to verify the external IP %s: %s", externalIP, err)
+		return nil
+	}
+	for _, node := range nodes.Items {
+		for _, address := range node.Status.Addresses {
+			if address.Address == externalIP {
+				return nil
+			}
+		}
+	}
+	message := fmt.Sprintf("the external IP %s is not the address of any node so the NodePort ingress controller Service %s/%s may not be reachable",
+		externalIP, ingressNamespace, ingressService)
+	if o.StrictExternalIP {
+		return errors.New(message)
+	}
+	log.Logger().Warn(message)
+	return nil
+}
+
+// FindFirstExternalNodeIP returns the first external IP address of the nodes in the cluster which can be used to
+// access a NodePort service
+func FindFirstExternalNodeIP(client kubernetes.Interface) (string, error) {
+	nodes, err := client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return "", errors.Wrap(err, "listing the nodes to find an external IP for the NodePort ingress service")
+	}
+	for _, node := range nodes.Items {
+		for _, address := range node.Status.Addresses {
+			if address.Type == corev1.NodeExternalIP && address.Address != "" {
+				return address.Address, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// privateIPBlocks the address blocks which are not publicly routable
+var privateIPBlocks = parseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7")
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	answer := []*net.IPNet{}
+	for _, cidr := range cidrs {
+		_, block, err := net.ParseCIDR(cidr)
+		if err == nil {
+			answer = append(answer, block)
+		}
+	}
+	return answer
+}
+
+// isPublicIP returns true if the address is a publicly routable IP address
+func isPublicIP(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil || !ip.IsGlobalUnicast() {
+		return false
+	}
+	for _, block := range privateIPBlocks {
+		if block.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// findFirstPublicPodHostIP returns the first publicly routable host IP address of the pods selected by the NodePort
+// ingress service, for clusters whose nodes do not have external IP addresses
+func findFirstPublicPodHostIP(client kubernetes.Interface, svc *corev1.Service) (string, error) {
+	if len(svc.Spec.Selector) == 0 {
+		return "", nil
+	}
+	selector := labels.SelectorFromSet(svc.Spec.Selector).String()
+	pods, err := client.CoreV1().Pods(svc.Namespace).List(metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "listing the ingress controller pods in namespace %s with selector %s", svc.Namespace, selector)
+	}
+	for _, pod := range pods.Items {
+		if isPublicIP(pod.Status.HostIP) {
+			return pod.Status.HostIP, nil
+		}
+	}
+	return "", nil
+}
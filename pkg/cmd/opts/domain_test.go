@@ -0,0 +1,679 @@
+// +build unit
+
+package opts_test
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/jx/pkg/cloud"
+	"github.com/jenkins-x/jx/pkg/cmd/opts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type fakeDNSRegistrar struct {
+	domains   []string
+	addresses []string
+}
+
+func (r *fakeDNSRegistrar) Register(domain, address string) (bool, error) {
+	r.domains = append(r.domains, domain)
+	r.addresses = append(r.addresses, address)
+	return true, nil
+}
+
+type fakeResolver struct {
+	ips map[string][]net.IP
+}
+
+func (r *fakeResolver) LookupIP(host string) ([]net.IP, error) {
+	return r.ips[host], nil
+}
+
+// flakyResolver fails to resolve until it has been called more than the given number of times
+type flakyResolver struct {
+	failures int
+	calls    int
+	ips      []net.IP
+}
+
+func (r *flakyResolver) LookupIP(host string) ([]net.IP, error) {
+	r.calls++
+	if r.calls <= r.failures {
+		return nil, fmt.Errorf("no such host %s", host)
+	}
+	return r.ips, nil
+}
+
+func TestGetDomainInvokesDNSRegistrarForProvider(t *testing.T) {
+	t.Parallel()
+
+	provider := "fake-dns-provider"
+	registrar := &fakeDNSRegistrar{}
+	opts.RegisterDNSRegistrar(provider, registrar)
+
+	o := &opts.CommonOptions{BatchMode: true}
+	domain, err := o.GetDomain(fake.NewSimpleClientset(), "example.com", provider, "kube-system", "jxing-nginx-ingress-controller", "1.2.3.4")
+	require.NoError(t, err)
+
+	assert.Equal(t, "example.com", domain)
+	assert.Equal(t, []string{"example.com"}, registrar.domains)
+	assert.Equal(t, []string{"1.2.3.4"}, registrar.addresses)
+}
+
+func TestGetDomainWithoutDNSRegistrar(t *testing.T) {
+	t.Parallel()
+
+	registrar := &fakeDNSRegistrar{}
+	opts.RegisterDNSRegistrar("fake-other-provider", registrar)
+
+	o := &opts.CommonOptions{BatchMode: true}
+	domain, err := o.GetDomain(fake.NewSimpleClientset(), "", "fake-unregistered-provider", "kube-system", "jxing-nginx-ingress-controller", "1.2.3.4")
+	require.NoError(t, err)
+
+	assert.Equal(t, "1.2.3.4.nip.io", domain)
+	assert.Empty(t, registrar.domains)
+}
+
+func TestGetDomainSkipDNSRegistration(t *testing.T) {
+	// registers a fake provider rather than replacing the registrar of a real one for the rest of the tests
+	provider := "fake-skip-dns-provider"
+	registrar := &fakeDNSRegistrar{}
+	opts.RegisterDNSRegistrar(provider, registrar)
+
+	o := &opts.CommonOptions{BatchMode: true, SkipDNSRegistration: true}
+	domain, err := o.GetDomain(fake.NewSimpleClientset(), "", provider, "kube-system", "jxing-nginx-ingress-controller", "1.2.3.4")
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.3.4.nip.io", domain)
+
+	domain, err = o.GetDomain(fake.NewSimpleClientset(), "example.com", provider, "kube-system", "jxing-nginx-ingress-controller", "1.2.3.4")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", domain)
+	assert.Empty(t, registrar.domains, "no DNS registration should be attempted")
+}
+
+func TestGetDomainPlanOnly(t *testing.T) {
+	// registers a fake provider rather than replacing the registrar of a real one for the rest of the tests
+	provider := "fake-plan-only-provider"
+	registrar := &fakeDNSRegistrar{}
+	opts.RegisterDNSRegistrar(provider, registrar)
+
+	o := &opts.CommonOptions{BatchMode: true, PlanOnly: true}
+	domain, err := o.GetDomain(fake.NewSimpleClientset(), "example.com", provider, "kube-system", "jxing-nginx-ingress-controller", "a1234.eu-west-1.elb.amazonaws.com")
+	require.NoError(t, err)
+
+	assert.Equal(t, "example.com", domain)
+	assert.Empty(t, registrar.domains, "no DNS registration should be attempted in plan only mode")
+}
+
+func TestGetDomainRancherUsesNodePortExternalIP(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      opts.DefaultIngressServiceName,
+				Namespace: opts.DefaultIngressNamesapce,
+			},
+			Spec: corev1.ServiceSpec{
+				Type: corev1.ServiceTypeNodePort,
+			},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "node-1",
+			},
+			Status: corev1.NodeStatus{
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+					{Type: corev1.NodeExternalIP, Address: "5.6.7.8"},
+				},
+			},
+		},
+	)
+
+	o := &opts.CommonOptions{BatchMode: true}
+	domain, err := o.GetDomain(client, "", cloud.RKE, opts.DefaultIngressNamesapce, opts.DefaultIngressServiceName, "")
+	require.NoError(t, err)
+
+	assert.Equal(t, "5.6.7.8.nip.io", domain)
+}
+
+func TestGetDomainVerifiesNodePortExternalIP(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      opts.DefaultIngressServiceName,
+				Namespace: opts.DefaultIngressNamesapce,
+			},
+			Spec: corev1.ServiceSpec{
+				Type: corev1.ServiceTypeNodePort,
+			},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "node-1",
+			},
+			Status: corev1.NodeStatus{
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+					{Type: corev1.NodeExternalIP, Address: "5.6.7.8"},
+				},
+			},
+		},
+	)
+
+	o := &opts.CommonOptions{BatchMode: true}
+	domain, err := o.GetDomain(client, "", cloud.KUBERNETES, opts.DefaultIngressNamesapce, opts.DefaultIngressServiceName, "1.1.1.1")
+	require.NoError(t, err, "a bogus external IP should only be warned about by default")
+	assert.Equal(t, "1.1.1.1.nip.io", domain)
+
+	o.StrictExternalIP = true
+	_, err = o.GetDomain(client, "", cloud.KUBERNETES, opts.DefaultIngressNamesapce, opts.DefaultIngressServiceName, "1.1.1.1")
+	assert.Error(t, err)
+
+	domain, err = o.GetDomain(client, "", cloud.KUBERNETES, opts.DefaultIngressNamesapce, opts.DefaultIngressServiceName, "5.6.7.8")
+	require.NoError(t, err)
+	assert.Equal(t, "5.6.7.8.nip.io", domain)
+}
+
+func TestGetDomainHetznerCloudUsesLoadBalancerIP(t *testing.T) {
+	t.Parallel()
+
+	// the Hetzner Cloud controller manager adds the host name of the LoadBalancer along with its IP
+	client := fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "jxing-nginx-ingress-controller",
+				Namespace: "kube-system",
+			},
+			Spec: corev1.ServiceSpec{
+				Type: corev1.ServiceTypeLoadBalancer,
+			},
+			Status: corev1.ServiceStatus{
+				LoadBalancer: corev1.LoadBalancerStatus{
+					Ingress: []corev1.LoadBalancerIngress{
+						{Hostname: "lb.example.com"},
+						{IP: "95.217.1.2"},
+					},
+				},
+			},
+		},
+	)
+
+	o := &opts.CommonOptions{BatchMode: true}
+	domain, err := o.GetDomain(client, "", cloud.HCLOUD, "kube-system", "jxing-nginx-ingress-controller", "")
+	require.NoError(t, err)
+	assert.Equal(t, "95.217.1.2.nip.io", domain)
+}
+
+func TestGetDomains(t *testing.T) {
+	t.Parallel()
+
+	loadBalancer := func(ns string, name string, ip string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: ns,
+			},
+			Spec: corev1.ServiceSpec{
+				Type: corev1.ServiceTypeLoadBalancer,
+			},
+			Status: corev1.ServiceStatus{
+				LoadBalancer: corev1.LoadBalancerStatus{
+					Ingress: []corev1.LoadBalancerIngress{{IP: ip}},
+				},
+			},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		loadBalancer("ingress-internal", "nginx-internal", "10.0.0.5"),
+		loadBalancer("ingress-external", "nginx-external", "35.1.2.3"),
+	)
+
+	o := &opts.CommonOptions{BatchMode: true}
+	domains, err := o.GetDomains(client, cloud.GKE, []opts.IngressRef{
+		{Name: "internal", Namespace: "ingress-internal", Service: "nginx-internal"},
+		{Namespace: "ingress-external", Service: "nginx-external"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"internal":                        "10.0.0.5.nip.io",
+		"ingress-external/nginx-external": "35.1.2.3.nip.io",
+	}, domains)
+
+	_, err = o.GetDomains(client, cloud.GKE, []opts.IngressRef{
+		{Namespace: "ingress-external", Service: "nginx-external"},
+		{Namespace: "ingress-missing", Service: "nginx-missing"},
+	})
+	assert.Error(t, err)
+}
+
+func TestGetDomainCivoUsesLoadBalancerIP(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "jxing-nginx-ingress-controller",
+				Namespace: "kube-system",
+			},
+			Spec: corev1.ServiceSpec{
+				Type: corev1.ServiceTypeLoadBalancer,
+			},
+			Status: corev1.ServiceStatus{
+				LoadBalancer: corev1.LoadBalancerStatus{
+					Ingress: []corev1.LoadBalancerIngress{{IP: "74.220.1.2"}},
+				},
+			},
+		},
+	)
+
+	o := &opts.CommonOptions{BatchMode: true}
+	domain, err := o.GetDomain(client, "", cloud.CIVO, "kube-system", "jxing-nginx-ingress-controller", "")
+	require.NoError(t, err)
+	assert.Equal(t, "74.220.1.2.nip.io", domain)
+}
+
+func TestGetDomainPreferHostname(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "jxing-nginx-ingress-controller",
+				Namespace: "kube-system",
+			},
+			Spec: corev1.ServiceSpec{
+				Type: corev1.ServiceTypeLoadBalancer,
+			},
+			Status: corev1.ServiceStatus{
+				LoadBalancer: corev1.LoadBalancerStatus{
+					Ingress: []corev1.LoadBalancerIngress{
+						{IP: "35.1.2.3"},
+						{Hostname: "lb.example.com"},
+					},
+				},
+			},
+		},
+	)
+
+	o := &opts.CommonOptions{
+		BatchMode: true,
+		Resolver:  &fakeResolver{},
+	}
+	domain, err := o.GetDomain(client, "", cloud.GKE, "kube-system", "jxing-nginx-ingress-controller", "")
+	require.NoError(t, err)
+	assert.Equal(t, "35.1.2.3.nip.io", domain)
+
+	o.PreferHostname = true
+	domain, err = o.GetDomain(client, "", cloud.GKE, "kube-system", "jxing-nginx-ingress-controller", "")
+	require.NoError(t, err)
+	assert.Equal(t, "lb.example.com", domain)
+}
+
+func TestGetDomainMinikubePrefersTunnelLoadBalancerIP(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      opts.DefaultIngressServiceName,
+				Namespace: opts.DefaultIngressNamesapce,
+			},
+			Spec: corev1.ServiceSpec{
+				Type: corev1.ServiceTypeLoadBalancer,
+			},
+			Status: corev1.ServiceStatus{
+				LoadBalancer: corev1.LoadBalancerStatus{
+					Ingress: []corev1.LoadBalancerIngress{
+						{IP: "10.96.184.178"},
+					},
+				},
+			},
+		},
+	)
+
+	o := &opts.CommonOptions{BatchMode: true}
+	domain, err := o.GetDomain(client, "", cloud.MINIKUBE, opts.DefaultIngressNamesapce, opts.DefaultIngressServiceName, "")
+	require.NoError(t, err)
+
+	assert.Equal(t, "10.96.184.178.nip.io", domain)
+}
+
+func TestGetDomainNodePortUsesPublicPodHostIP(t *testing.T) {
+	t.Parallel()
+
+	controllerLabels := map[string]string{
+		"app": "nginx-ingress",
+	}
+	client := fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      opts.DefaultIngressServiceName,
+				Namespace: opts.DefaultIngressNamesapce,
+			},
+			Spec: corev1.ServiceSpec{
+				Type:     corev1.ServiceTypeNodePort,
+				Selector: controllerLabels,
+			},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "node-1",
+			},
+			Status: corev1.NodeStatus{
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+				},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "jxing-nginx-ingress-controller-private",
+				Namespace: opts.DefaultIngressNamesapce,
+				Labels:    controllerLabels,
+			},
+			Status: corev1.PodStatus{
+				HostIP: "10.0.0.1",
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "jxing-nginx-ingress-controller-public",
+				Namespace: opts.DefaultIngressNamesapce,
+				Labels:    controllerLabels,
+			},
+			Status: corev1.PodStatus{
+				HostIP: "35.1.2.3",
+			},
+		},
+	)
+
+	o := &opts.CommonOptions{BatchMode: true}
+	domain, err := o.GetDomain(client, "", cloud.RKE, opts.DefaultIngressNamesapce, opts.DefaultIngressServiceName, "")
+	require.NoError(t, err)
+
+	assert.Equal(t, "35.1.2.3.nip.io", domain)
+}
+
+func TestGetDomainResolvesHostNameWithResolver(t *testing.T) {
+	t.Parallel()
+
+	o := &opts.CommonOptions{
+		BatchMode: true,
+		Resolver: &fakeResolver{
+			ips: map[string][]net.IP{
+				"ingress.example.com": {net.ParseIP("35.1.2.3")},
+			},
+		},
+	}
+	domain, err := o.GetDomain(fake.NewSimpleClientset(), "", "fake-resolver-provider", "kube-system", "jxing-nginx-ingress-controller", "ingress.example.com")
+	require.NoError(t, err)
+
+	assert.Equal(t, "35.1.2.3.nip.io", domain)
+}
+
+func TestGetDomainUsesResolvableHostNameAsDomain(t *testing.T) {
+	t.Parallel()
+
+	o := &opts.CommonOptions{
+		BatchMode:           true,
+		UseHostnameAsDomain: true,
+		Resolver: &fakeResolver{
+			ips: map[string][]net.IP{
+				"apps.example.com":                    {net.ParseIP("35.1.2.3")},
+				"myapp.westeurope.cloudapp.azure.com": {net.ParseIP("35.1.2.4")},
+				"35.1.2.5.sslip.io":                   {net.ParseIP("35.1.2.5")},
+			},
+		},
+	}
+	domain, err := o.GetDomain(fake.NewSimpleClientset(), "", "fake-resolver-provider", "kube-system", "jxing-nginx-ingress-controller", "apps.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "apps.example.com", domain)
+
+	// host names generated for cloud load balancers are still resolved to an IP address
+	domain, err = o.GetDomain(fake.NewSimpleClientset(), "", "fake-resolver-provider", "kube-system", "jxing-nginx-ingress-controller", "myapp.westeurope.cloudapp.azure.com")
+	require.NoError(t, err)
+	assert.Equal(t, "35.1.2.4.nip.io", domain)
+
+	// as are auto DNS domains
+	domain, err = o.GetDomain(fake.NewSimpleClientset(), "", "fake-resolver-provider", "kube-system", "jxing-nginx-ingress-controller", "35.1.2.5.sslip.io")
+	require.NoError(t, err)
+	assert.Equal(t, "35.1.2.5.nip.io", domain)
+}
+
+func TestGetDomainSkipHostnameResolution(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "jxing-nginx-ingress-controller",
+				Namespace: "kube-system",
+			},
+			Spec: corev1.ServiceSpec{
+				Type: corev1.ServiceTypeLoadBalancer,
+			},
+			Status: corev1.ServiceStatus{
+				LoadBalancer: corev1.LoadBalancerStatus{
+					Ingress: []corev1.LoadBalancerIngress{{Hostname: "ingress.example.com"}},
+				},
+			},
+		},
+	)
+
+	resolver := &flakyResolver{ips: []net.IP{net.ParseIP("35.1.2.3")}}
+	o := &opts.CommonOptions{
+		BatchMode:              true,
+		Resolver:               resolver,
+		SkipHostnameResolution: true,
+	}
+	domain, err := o.GetDomain(client, "", cloud.GKE, "kube-system", "jxing-nginx-ingress-controller", "")
+	require.NoError(t, err)
+	assert.Equal(t, "ingress.example.com", domain)
+	assert.Equal(t, 0, resolver.calls, "the host name should not be resolved")
+}
+
+func TestGetDomainResolvesHostNameAfterRetries(t *testing.T) {
+	t.Parallel()
+
+	sleeps := []time.Duration{}
+	resolver := &flakyResolver{
+		failures: 3,
+		ips:      []net.IP{net.ParseIP("35.1.2.3")},
+	}
+	o := &opts.CommonOptions{
+		BatchMode: true,
+		Resolver:  resolver,
+		Sleep: func(d time.Duration) {
+			sleeps = append(sleeps, d)
+		},
+	}
+	domain, err := o.GetDomain(fake.NewSimpleClientset(), "", "fake-resolver-provider", "kube-system", "jxing-nginx-ingress-controller", "ingress.example.com")
+	require.NoError(t, err)
+
+	assert.Equal(t, "35.1.2.3.nip.io", domain)
+	assert.Equal(t, 4, resolver.calls)
+	assert.Equal(t, []time.Duration{10 * time.Second, 10 * time.Second}, sleeps)
+}
+
+func TestGetDomainLoadBalancerPending(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "jxing-nginx-ingress-controller",
+				Namespace: "kube-system",
+			},
+			Spec: corev1.ServiceSpec{
+				Type: corev1.ServiceTypeLoadBalancer,
+			},
+		},
+	)
+
+	o := &opts.CommonOptions{BatchMode: true}
+	domain, err := o.GetDomain(client, "", "fake-unregistered-provider", "kube-system", "jxing-nginx-ingress-controller", "")
+	assert.Equal(t, opts.ErrLoadBalancerPending, err)
+	assert.Equal(t, "", domain)
+
+	// an explicit domain does not need the address of the LoadBalancer
+	domain, err = o.GetDomain(client, "example.com", "fake-unregistered-provider", "kube-system", "jxing-nginx-ingress-controller", "")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", domain)
+}
+
+func TestGetDomainOverrideFunc(t *testing.T) {
+	t.Parallel()
+
+	o := &opts.CommonOptions{
+		BatchMode: true,
+		DomainOverrideFunc: func(address string, provider string) (string, bool) {
+			if address == "1.2.3.4" && provider == "fake-override-provider" {
+				return "apps.example.com", true
+			}
+			return "", false
+		},
+	}
+	domain, err := o.GetDomain(fake.NewSimpleClientset(), "", "fake-override-provider", "kube-system", "jxing-nginx-ingress-controller", "1.2.3.4")
+	require.NoError(t, err)
+	assert.Equal(t, "apps.example.com", domain)
+
+	// the default domain is used if the hook cannot derive one
+	domain, err = o.GetDomain(fake.NewSimpleClientset(), "", "fake-override-provider", "kube-system", "jxing-nginx-ingress-controller", "5.6.7.8")
+	require.NoError(t, err)
+	assert.Equal(t, "5.6.7.8.nip.io", domain)
+}
+
+func TestGetDomainRequireExplicitDomain(t *testing.T) {
+	t.Parallel()
+
+	o := &opts.CommonOptions{BatchMode: true, RequireExplicitDomain: true}
+	_, err := o.GetDomain(fake.NewSimpleClientset(), "", "fake-unregistered-provider", "kube-system", "jxing-nginx-ingress-controller", "1.2.3.4")
+	assert.Error(t, err)
+
+	domain, err := o.GetDomain(fake.NewSimpleClientset(), "example.com", "fake-unregistered-provider", "kube-system", "jxing-nginx-ingress-controller", "1.2.3.4")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", domain)
+}
+
+func TestGetDomainPrivateIKSUsesPrivateAddress(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      opts.DefaultIngressServiceName,
+				Namespace: opts.DefaultIngressNamesapce,
+			},
+			Spec: corev1.ServiceSpec{
+				Type: corev1.ServiceTypeLoadBalancer,
+			},
+			Status: corev1.ServiceStatus{
+				LoadBalancer: corev1.LoadBalancerStatus{
+					Ingress: []corev1.LoadBalancerIngress{
+						{IP: "10.0.0.5"},
+					},
+				},
+			},
+		},
+	)
+
+	o := &opts.CommonOptions{BatchMode: true, PrivateCluster: true}
+	domain, err := o.GetDomain(client, "", cloud.IKS, opts.DefaultIngressNamesapce, opts.DefaultIngressServiceName, "")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.5.nip.io", domain)
+
+	pending := fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      opts.DefaultIngressServiceName,
+				Namespace: opts.DefaultIngressNamesapce,
+			},
+			Spec: corev1.ServiceSpec{
+				Type: corev1.ServiceTypeLoadBalancer,
+			},
+		},
+	)
+	_, err = o.GetDomain(pending, "", cloud.IKS, opts.DefaultIngressNamesapce, opts.DefaultIngressServiceName, "")
+	assert.Error(t, err)
+}
+
+func TestGetDomainUsesExternalIPFromConfigMap(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      opts.DefaultIngressServiceName,
+				Namespace: opts.DefaultIngressNamesapce,
+			},
+			Spec: corev1.ServiceSpec{
+				Type: corev1.ServiceTypeLoadBalancer,
+			},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "jx-ingress-config",
+				Namespace: opts.DefaultIngressNamesapce,
+			},
+			Data: map[string]string{
+				"externalIP": "5.6.7.8",
+			},
+		},
+	)
+
+	o := &opts.CommonOptions{
+		BatchMode:           true,
+		ExternalIPConfigMap: "jx-ingress-config",
+	}
+	domain, err := o.GetDomain(client, "", cloud.KUBERNETES, opts.DefaultIngressNamesapce, opts.DefaultIngressServiceName, "")
+	require.NoError(t, err)
+
+	assert.Equal(t, "5.6.7.8.nip.io", domain)
+}
+
+func TestGetDomainCandidates(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      opts.DefaultIngressServiceName,
+				Namespace: opts.DefaultIngressNamesapce,
+			},
+			Spec: corev1.ServiceSpec{
+				Type: corev1.ServiceTypeLoadBalancer,
+			},
+			Status: corev1.ServiceStatus{
+				LoadBalancer: corev1.LoadBalancerStatus{
+					Ingress: []corev1.LoadBalancerIngress{
+						{Hostname: "lb.example.com", IP: "1.2.3.4"},
+					},
+				},
+			},
+		},
+	)
+
+	o := &opts.CommonOptions{BatchMode: true}
+	candidates, err := o.GetDomainCandidates(client, cloud.KUBERNETES, opts.DefaultIngressNamesapce, opts.DefaultIngressServiceName, "")
+	require.NoError(t, err)
+
+	assert.Equal(t, []opts.DomainCandidate{
+		{Domain: "1.2.3.4.nip.io", Address: "1.2.3.4", Source: opts.DomainSourceLoadBalancerIP, Priority: 1},
+		{Domain: "lb.example.com", Address: "lb.example.com", Source: opts.DomainSourceLoadBalancerHostname, Priority: 2},
+	}, candidates)
+
+	domain, err := o.GetDomain(client, "", cloud.KUBERNETES, opts.DefaultIngressNamesapce, opts.DefaultIngressServiceName, "")
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.3.4.nip.io", domain)
+}
@@ -2,11 +2,31 @@ package opts
 
 import (
 	jenkinsv1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/config"
 	"github.com/jenkins-x/jx/pkg/kube"
 	"github.com/jenkins-x/jx/pkg/kube/services"
 	"github.com/jenkins-x/jx/pkg/log"
 )
 
+const (
+	// ArtifactSourceRequirements the artifact repository was configured in the team's requirements
+	ArtifactSourceRequirements = "requirements"
+	// ArtifactSourceCluster the artifact repository was discovered from the resources in the cluster
+	ArtifactSourceCluster = "cluster"
+)
+
+// ArtifactRepository the location of an artifact repository and where it was resolved from
+type ArtifactRepository struct {
+	URL    string
+	Source string
+}
+
+// ArtifactRepositories the chart repository and container registry used by the team
+type ArtifactRepositories struct {
+	ChartRepository   ArtifactRepository
+	ContainerRegistry ArtifactRepository
+}
+
 // RegisterEnvironmentCRD registers the CRD for environmnt
 func (o *CommonOptions) RegisterEnvironmentCRD() error {
 	apisClient, err := o.ApiExtensionsClient()
@@ -32,24 +52,86 @@ func (o *CommonOptions) GetDevEnv() (gitOps bool, devEnv *jenkinsv1.Environment)
 			log.Logger().Errorf("Error loading team settings. %v", err)
 			return false, &jenkinsv1.Environment{}
 		}
-		gitOps := false
 		if devEnv == nil {
 			devEnv = &jenkinsv1.Environment{}
 			devEnv.Spec.Namespace = ns
 		}
-		if devEnv.Spec.Source.URL != "" {
-			gitOps = true
-		}
-		return gitOps, devEnv
+		return IsGitOpsEnvironment(devEnv), devEnv
 	}
 }
 
+// IsGitOpsEnvironment returns true if the environment is using gitOps, i.e. it has a source git repository
+func IsGitOpsEnvironment(env *jenkinsv1.Environment) bool {
+	return env != nil && env.Spec.Source.URL != ""
+}
+
 // ResolveChartMuseumURL resolves the current Chart Museum URL so we can pass it into a remote Environment's
-// git repository
+// git repository. The URL is cached for the lifetime of the options, see InvalidateChartMuseumURL
 func (o *CommonOptions) ResolveChartMuseumURL() (string, error) {
+	if o.chartMuseumURL != "" {
+		return o.chartMuseumURL, nil
+	}
 	kubeClient, ns, err := o.KubeClientAndDevNamespace()
 	if err != nil {
 		return "", err
 	}
-	return services.FindServiceURL(kubeClient, ns, kube.ServiceChartMuseum)
+	url, err := services.FindServiceURL(kubeClient, ns, kube.ServiceChartMuseum)
+	if err != nil {
+		return "", err
+	}
+	o.chartMuseumURL = url
+	return url, nil
+}
+
+// InvalidateChartMuseumURL clears the cached Chart Museum URL so that the next call to ResolveChartMuseumURL
+// looks it up again
+func (o *CommonOptions) InvalidateChartMuseumURL() {
+	o.chartMuseumURL = ""
+}
+
+// ResolveArtifactRepositories resolves the chart repository and container registry in one pass, using the values
+// in the team's requirements if present otherwise discovering them from the cluster. A repository which cannot be
+// resolved is left empty
+func (o *CommonOptions) ResolveArtifactRepositories() (*ArtifactRepositories, error) {
+	answer := &ArtifactRepositories{}
+
+	kubeClient, ns, err := o.KubeClientAndDevNamespace()
+	if err != nil {
+		return answer, err
+	}
+
+	var requirements *config.RequirementsConfig
+	teamSettings, err := o.TeamSettings()
+	if err != nil {
+		log.Logger().Debugf("unable to load the team settings: %s", err)
+	} else {
+		requirements, err = config.GetRequirementsConfigFromTeamSettings(teamSettings)
+		if err != nil {
+			log.Logger().Debugf("unable to load the requirements from the team settings: %s", err)
+		}
+	}
+
+	if requirements != nil && requirements.Cluster.ChartRepository != "" {
+		answer.ChartRepository = ArtifactRepository{URL: requirements.Cluster.ChartRepository, Source: ArtifactSourceRequirements}
+	} else {
+		url, err := o.ResolveChartMuseumURL()
+		if err != nil {
+			log.Logger().Debugf("unable to discover the chart repository in namespace %s: %s", ns, err)
+		} else if url != "" {
+			answer.ChartRepository = ArtifactRepository{URL: url, Source: ArtifactSourceCluster}
+		}
+	}
+
+	if requirements != nil && requirements.Cluster.Registry != "" {
+		answer.ContainerRegistry = ArtifactRepository{URL: requirements.Cluster.Registry, Source: ArtifactSourceRequirements}
+	} else {
+		name := kube.ConfigMapJenkinsDockerRegistry
+		data, err := kube.GetConfigMapData(kubeClient, name, ns)
+		if err != nil {
+			log.Logger().Debugf("unable to discover the container registry from ConfigMap %s in namespace %s: %s", name, ns, err)
+		} else if data["docker.registry"] != "" {
+			answer.ContainerRegistry = ArtifactRepository{URL: data["docker.registry"], Source: ArtifactSourceCluster}
+		}
+	}
+	return answer, nil
 }
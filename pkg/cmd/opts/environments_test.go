@@ -0,0 +1,168 @@
+// +build unit
+
+package opts_test
+
+import (
+	"testing"
+
+	"github.com/ghodss/yaml"
+	v1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/cmd/opts"
+	"github.com/jenkins-x/jx/pkg/cmd/testhelpers"
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/gits"
+	"github.com/jenkins-x/jx/pkg/helm"
+	"github.com/jenkins-x/jx/pkg/kube"
+	resources_test "github.com/jenkins-x/jx/pkg/kube/resources/mocks"
+	"github.com/jenkins-x/jx/pkg/kube/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestResolveArtifactRepositories(t *testing.T) {
+	t.Parallel()
+
+	requirements := config.NewRequirementsConfig()
+	requirements.Cluster.ChartRepository = "http://charts.example.com"
+	requirements.Cluster.Registry = "registry.example.com"
+	data, err := yaml.Marshal(requirements)
+	require.NoError(t, err)
+
+	devEnvWithRequirements := kube.NewPermanentEnvironment("dev")
+	devEnvWithRequirements.Spec.Namespace = "jx"
+	devEnvWithRequirements.Spec.Kind = v1.EnvironmentKindTypeDevelopment
+	devEnvWithRequirements.Spec.TeamSettings.BootRequirements = string(data)
+
+	clusterObjects := []runtime.Object{
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      kube.ServiceChartMuseum,
+				Namespace: "jx",
+				Annotations: map[string]string{
+					services.ExposeURLAnnotation: "http://chartmuseum.jx.1.2.3.4.nip.io",
+				},
+			},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      kube.ConfigMapJenkinsDockerRegistry,
+				Namespace: "jx",
+			},
+			Data: map[string]string{
+				"docker.registry": "10.0.0.1:5000",
+			},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		jxObjects []runtime.Object
+		expected  opts.ArtifactRepositories
+	}{
+		{
+			name:      "from requirements",
+			jxObjects: []runtime.Object{devEnvWithRequirements},
+			expected: opts.ArtifactRepositories{
+				ChartRepository:   opts.ArtifactRepository{URL: "http://charts.example.com", Source: opts.ArtifactSourceRequirements},
+				ContainerRegistry: opts.ArtifactRepository{URL: "registry.example.com", Source: opts.ArtifactSourceRequirements},
+			},
+		},
+		{
+			name: "discovered from the cluster",
+			expected: opts.ArtifactRepositories{
+				ChartRepository:   opts.ArtifactRepository{URL: "http://chartmuseum.jx.1.2.3.4.nip.io", Source: opts.ArtifactSourceCluster},
+				ContainerRegistry: opts.ArtifactRepository{URL: "10.0.0.1:5000", Source: opts.ArtifactSourceCluster},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		o := &opts.CommonOptions{}
+		testhelpers.ConfigureTestOptionsWithResources(o,
+			clusterObjects,
+			tt.jxObjects,
+			gits.NewGitCLI(),
+			nil,
+			helm.NewHelmCLI("helm", helm.V2, "", true),
+			resources_test.NewMockInstaller(),
+		)
+
+		repositories, err := o.ResolveArtifactRepositories()
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.expected, *repositories, tt.name)
+	}
+}
+
+func TestResolveChartMuseumURLIsCached(t *testing.T) {
+	t.Parallel()
+
+	kubeClient := fake.NewSimpleClientset(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      kube.ServiceChartMuseum,
+			Namespace: "jx",
+			Annotations: map[string]string{
+				services.ExposeURLAnnotation: "http://chartmuseum.jx.example.com",
+			},
+		},
+	})
+	serviceLookups := func() int {
+		count := 0
+		for _, action := range kubeClient.Actions() {
+			if action.GetVerb() == "get" && action.GetResource().Resource == "services" {
+				count++
+			}
+		}
+		return count
+	}
+
+	o := &opts.CommonOptions{}
+	o.SetDevNamespace("jx")
+	o.SetKubeClient(kubeClient)
+
+	for i := 0; i < 3; i++ {
+		url, err := o.ResolveChartMuseumURL()
+		require.NoError(t, err)
+		assert.Equal(t, "http://chartmuseum.jx.example.com", url)
+	}
+	assert.Equal(t, 1, serviceLookups())
+
+	o.InvalidateChartMuseumURL()
+	url, err := o.ResolveChartMuseumURL()
+	require.NoError(t, err)
+	assert.Equal(t, "http://chartmuseum.jx.example.com", url)
+	assert.Equal(t, 2, serviceLookups())
+}
+
+func TestIsGitOpsEnvironment(t *testing.T) {
+	t.Parallel()
+
+	environment := func(name string, kind v1.EnvironmentKindType, sourceURL string) *v1.Environment {
+		env := kube.NewPermanentEnvironment(name)
+		env.Spec.Kind = kind
+		env.Spec.Source.URL = sourceURL
+		return env
+	}
+
+	tests := []struct {
+		name string
+		env  *v1.Environment
+		want bool
+	}{
+		{"dev with source", environment("dev", v1.EnvironmentKindTypeDevelopment, "https://github.com/my-org/environment-dev.git"), true},
+		{"dev without source", environment("dev", v1.EnvironmentKindTypeDevelopment, ""), false},
+		{"staging with source", environment("staging", v1.EnvironmentKindTypePermanent, "https://github.com/my-org/environment-staging.git"), true},
+		{"remote production with source", environment("production", v1.EnvironmentKindTypePermanent, "https://github.com/my-org/environment-production.git"), true},
+		{"preview without source", environment("pr-1", v1.EnvironmentKindTypePreview, ""), false},
+		{"nil environment", nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, opts.IsGitOpsEnvironment(test.env))
+		})
+	}
+}
@@ -1054,7 +1054,7 @@ func (o *CommonOptions) InstallProw(useTekton bool, useExternalDNS bool, isGitOp
 		"pipelinerunner.enabled=true",
 	)
 
-	if useExternalDNS && strings.Contains(o.Domain, "nip.io") {
+	if useExternalDNS && config.IsAutoDNSDomain(o.Domain) {
 		log.Logger().Warnf("Skipping install of External DNS, %s domain is not supported while using External DNS", util.ColorInfo(o.Domain))
 		log.Logger().Warnf("External DNS only supports the use of personally operated domains")
 	} else if useExternalDNS && o.Domain != "" {
@@ -1,11 +1,8 @@
 package step
 
 import (
-	"bufio"
-	"encoding/xml"
 	"fmt"
 	"io/ioutil"
-	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
@@ -35,6 +32,23 @@ const (
 	chartyaml   = "Chart.yaml"
 	pomxml      = "pom.xml"
 	makefile    = "Makefile"
+	dockerfile  = "Dockerfile"
+
+	// dockerVersionLabel the Dockerfile LABEL containing the version
+	dockerVersionLabel = "org.opencontainers.image.version"
+
+	// chartFieldVersion the Chart.yaml field containing the version of the chart
+	chartFieldVersion = "version"
+	// chartFieldAppVersion the Chart.yaml field containing the version of the application
+	chartFieldAppVersion = "appVersion"
+
+	// versionPrefixNone the --tag-prefix value used to write versions without any prefix
+	versionPrefixNone = "none"
+
+	// versionSchemeSemVer versions such as 1.2.3, see https://semver.org
+	versionSchemeSemVer = "semver"
+	// versionSchemeCalVer calendar versions such as 2020.01.2, see https://calver.org
+	versionSchemeCalVer = "calver"
 )
 
 // StepNextVersionOptions contains the command line flags
@@ -46,13 +60,28 @@ type StepNextVersionOptions struct {
 	UseGitTagOnly   bool
 	NewVersion      string
 	SemanticRelease bool
+	TagPrefix       string
+	ChartField      string
+	PinnedVersion   string
+	VersionScheme   string
+	YAMLPath        string
 	step.StepOptions
+
+	filePrefix string
 }
 
 type Project struct {
-	Version string `xml:"version"`
+	Version string   `xml:"version"`
+	Modules []string `xml:"modules>module"`
 }
 
+// calVerRegex matches calendar versions starting with a YYYY or YY year and a month, followed by an optional day or
+// sequence number and an optional micro number
+var calVerRegex = regexp.MustCompile(`^(\d{4}|\d{2})\.(0?[1-9]|1[0-2])(\.\d+){0,2}$`)
+
+// dockerVersionLabelRegex matches the version label in a LABEL instruction capturing the key and the value
+var dockerVersionLabelRegex = regexp.MustCompile(`(^|\s)("?` + regexp.QuoteMeta(dockerVersionLabel) + `"?\s*=\s*"?)([^"\s\\]+)`)
+
 type PackageJSON struct {
 	Version string `json:"version"`
 }
@@ -68,6 +97,16 @@ var (
 		jx step next-version --filename package.json --tag
 		jx step next-version --filename package.json --tag --version 1.2.3
 
+		# bump the appVersion rather than the version of a chart
+		jx step next-version --filename Chart.yaml --chart-field appVersion
+
+		# bump the image tag in the values.yaml of a chart
+		jx step next-version --filename charts/my-app/values.yaml --yaml-path image.tag
+
+		# jump to an explicit version rather than the next one
+		jx step next-version --filename package.json --set 2.0.0
+		jx step next-version --filename Makefile --set 2020.02.1 --version-scheme calver
+
 		# lets use git to create a new version from a tag and tag git
         jx step next-version --use-git-tag-only --tag
               
@@ -97,7 +136,12 @@ func NewCmdStepNextVersion(commonOpts *opts.CommonOptions) *cobra.Command {
 	cmd.Flags().StringVarP(&options.Dir, "dir", "d", "", "the directory to look for files that contain a pom.xml or Makefile with the project version to bump")
 	cmd.Flags().StringVarP(&options.ChartsDir, "charts-dir", "", "", "the directory of the chart to update the version (in conjunction with --tag)")
 	cmd.Flags().BoolVarP(&options.Tag, "tag", "t", false, "tag and push new version")
-	cmd.Flags().BoolVarP(&options.UseGitTagOnly, "use-git-tag-only", "", false, "only use a git tag so work out new semantic version, else specify filename [pom.xml,package.json,Makefile,Chart.yaml]")
+	cmd.Flags().BoolVarP(&options.UseGitTagOnly, "use-git-tag-only", "", false, "only use a git tag so work out new semantic version, else specify filename [pom.xml,package.json,Makefile,Chart.yaml,Dockerfile]")
+	cmd.Flags().StringVarP(&options.TagPrefix, "tag-prefix", "", "", fmt.Sprintf("the prefix of the version written to ./VERSION, the filename and the git tag, e.g. 'v'. Use '%s' for no prefix. Defaults to the prefix of the existing version in the filename and 'v' for the git tag", versionPrefixNone))
+	cmd.Flags().StringVarP(&options.ChartField, "chart-field", "", chartFieldVersion, fmt.Sprintf("the field of the Chart.yaml containing the version to bump, either '%s' or '%s'", chartFieldVersion, chartFieldAppVersion))
+	cmd.Flags().StringVarP(&options.YAMLPath, "yaml-path", "", "", "the dotted path of the field containing the version in a YAML filename, e.g. 'image.tag' in a values.yaml")
+	cmd.Flags().StringVarP(&options.PinnedVersion, "set", "", "", "the version to write rather than computing the next version, it is validated against the --version-scheme")
+	cmd.Flags().StringVarP(&options.VersionScheme, "version-scheme", "", versionSchemeSemVer, fmt.Sprintf("the scheme of the version specified with --set, either '%s' or '%s'", versionSchemeSemVer, versionSchemeCalVer))
 	cmd.Flags().BoolVarP(&options.SemanticRelease, "semantic-release", "", false, "use conventional commits to determine next version. Ignores the --use-git-tag-only and --version options See https://github.com/angular/angular.js/blob/master/DEVELOPERS.md#-git-commit-guidelines")
 	return cmd
 }
@@ -105,7 +149,13 @@ func NewCmdStepNextVersion(commonOpts *opts.CommonOptions) *cobra.Command {
 func (o *StepNextVersionOptions) Run() error {
 
 	var err error
-	if o.SemanticRelease {
+	if o.PinnedVersion != "" {
+		err = validateVersion(o.VersionScheme, o.PinnedVersion)
+		if err != nil {
+			return err
+		}
+		o.NewVersion = o.PinnedVersion
+	} else if o.SemanticRelease {
 		err := o.Git().FetchTags(o.Dir)
 		if err != nil {
 			return errors.WithStack(err)
@@ -130,6 +180,10 @@ func (o *StepNextVersionOptions) Run() error {
 		}
 	}
 
+	if o.TagPrefix != "" || o.Filename != "" {
+		o.NewVersion = o.versionPrefix() + strings.TrimPrefix(o.NewVersion, "v")
+	}
+
 	// in declarative pipelines we sometimes need to write the version to a file rather than pass state
 	err = ioutil.WriteFile("VERSION", []byte(o.NewVersion), 0755)
 	if err != nil {
@@ -150,8 +204,9 @@ func (o *StepNextVersionOptions) Run() error {
 	if o.Tag {
 		tagOptions := StepTagOptions{
 			Flags: StepTagFlags{
-				Version:   o.NewVersion,
+				Version:   strings.TrimPrefix(o.NewVersion, o.versionPrefix()),
 				ChartsDir: o.ChartsDir,
+				TagPrefix: o.TagPrefix,
 			},
 			StepOptions: o.StepOptions,
 		}
@@ -163,94 +218,110 @@ func (o *StepNextVersionOptions) Run() error {
 	return nil
 }
 
-// GetVersion gets the version from a source file
-func (o *StepNextVersionOptions) GetVersion() (string, error) {
-	if o.UseGitTagOnly {
-		return "", nil
+// validateVersion returns an error if the version, ignoring any leading 'v', is not a valid version of the scheme
+func validateVersion(scheme string, v string) error {
+	if hasVersionPrefix(v) {
+		v = v[1:]
 	}
-	if o.Filename == "" {
-		// try and work out
-		return "", fmt.Errorf("no filename flag set to work out next semantic version.  choose pom.xml, Chart.yaml, package.json, Makefile or set the flag use-git-tag-only")
-	}
-
-	switch o.Filename {
-	case chartyaml:
-		chartFile := filepath.Join(o.Dir, chartyaml)
-		chart, err := ioutil.ReadFile(chartFile)
+	switch scheme {
+	case versionSchemeSemVer, "":
+		_, err := semver.Parse(v)
 		if err != nil {
-			return "", err
+			return errors.Wrapf(err, "%s is not a valid semantic version", v)
 		}
-
-		log.Logger().Debugf("Found Chart.yaml")
-		scanner := bufio.NewScanner(strings.NewReader(string(chart)))
-		for scanner.Scan() {
-			if strings.Contains(scanner.Text(), "version") {
-				parts := strings.Split(scanner.Text(), ":")
-
-				v := strings.TrimSpace(parts[1])
-				if v != "" {
-					log.Logger().Debugf("existing Chart version %v", v)
-					return v, nil
-				}
-			}
-		}
-	case packagejson:
-		packageFile := filepath.Join(o.Dir, packagejson)
-		p, err := ioutil.ReadFile(packageFile)
-		if err != nil {
-			return "", err
+	case versionSchemeCalVer:
+		if !calVerRegex.MatchString(v) {
+			return fmt.Errorf("%s is not a valid calendar version such as 2020.01.2", v)
 		}
+	default:
+		return fmt.Errorf("unknown version scheme %s, supported schemes are %s and %s", scheme, versionSchemeSemVer, versionSchemeCalVer)
+	}
+	return nil
+}
 
-		log.Logger().Debugf("found %s", packagejson)
-
-		var jsPackage PackageJSON
-		json.Unmarshal(p, &jsPackage)
+// GetVersion gets the version from a source file, ignoring any leading 'v'
+func (o *StepNextVersionOptions) GetVersion() (string, error) {
+	v, err := o.getFileVersion()
+	if err != nil {
+		return v, err
+	}
+	if hasVersionPrefix(v) {
+		o.filePrefix = "v"
+		return v[1:], nil
+	}
+	return v, nil
+}
 
-		if jsPackage.Version != "" {
-			log.Logger().Debugf("existing version %s", jsPackage.Version)
-			return jsPackage.Version, nil
-		}
+// GetVersions gets all the version fields of the source file keyed by the field name, e.g. both the version and
+// appVersion of a Chart.yaml. Files with a single version return it as the version field. Unlike GetVersion any
+// leading 'v' is kept
+func (o *StepNextVersionOptions) GetVersions() (map[string]string, error) {
+	if o.Filename == "" {
+		return nil, fmt.Errorf("no filename flag set to read the versions from")
+	}
+	handler := o.versionHandler(o.Filename)
+	if handler == nil {
+		return nil, fmt.Errorf("no recognised file to obtain current version from")
+	}
+	if reader, ok := handler.(multiVersionReader); ok {
+		return reader.ReadVersions(o.Dir, o.Filename)
+	}
+	v, err := handler.Read(o.Dir, o.Filename)
+	if err != nil {
+		return nil, err
+	}
+	answer := map[string]string{}
+	if v != "" {
+		answer[chartFieldVersion] = v
+	}
+	return answer, nil
+}
 
-	case pomxml:
-		pomFile := filepath.Join(o.Dir, pomxml)
-		p, err := ioutil.ReadFile(pomFile)
-		if err != nil {
-			return "", err
+// versionPrefix returns the prefix of versions written by this step, defaulting to the prefix of the existing
+// version in the source file
+func (o *StepNextVersionOptions) versionPrefix() string {
+	switch o.TagPrefix {
+	case versionPrefixNone:
+		return ""
+	case "":
+		if o.Filename != "" && o.filePrefix == "" {
+			_, err := o.GetVersion()
+			if err != nil {
+				log.Logger().Debugf("unable to detect the version prefix used in %s: %s", o.Filename, err)
+			}
 		}
+		return o.filePrefix
+	default:
+		return o.TagPrefix
+	}
+}
 
-		log.Logger().Debugf("found pom.xml")
-		var project Project
-		xml.Unmarshal(p, &project)
-		if project.Version != "" {
-			log.Logger().Debugf("existing version %s", project.Version)
-			return project.Version, nil
-		}
+// hasVersionPrefix returns true if the version starts with a 'v' followed by a digit
+func hasVersionPrefix(v string) bool {
+	return len(v) > 1 && v[0] == 'v' && v[1] >= '0' && v[1] <= '9'
+}
 
-	case makefile:
-		makefile := filepath.Join(o.Dir, makefile)
-		m, err := ioutil.ReadFile(makefile)
-		if err != nil {
-			return "", err
-		}
+func (o *StepNextVersionOptions) getFileVersion() (string, error) {
+	if o.UseGitTagOnly {
+		return "", nil
+	}
+	if o.Filename == "" {
+		// try and work out
+		return "", fmt.Errorf("no filename flag set to work out next semantic version.  choose pom.xml, Chart.yaml, package.json, Makefile, Dockerfile or set the flag use-git-tag-only")
+	}
 
-		log.Logger().Debugf("found Makefile")
-		scanner := bufio.NewScanner(strings.NewReader(string(m)))
-		for scanner.Scan() {
-			if strings.HasPrefix(scanner.Text(), "VERSION") || strings.HasPrefix(scanner.Text(), "VERSION ") || strings.HasPrefix(scanner.Text(), "VERSION:") || strings.HasPrefix(scanner.Text(), "VERSION=") {
-				parts := strings.Split(scanner.Text(), "=")
-
-				v := strings.TrimSpace(parts[1])
-				if v != "" {
-					log.Logger().Debugf("existing Makefile version %s", v)
-					return v, nil
-				}
-			}
-		}
-	default:
+	handler := o.versionHandler(o.Filename)
+	if handler == nil {
 		return "", fmt.Errorf("no recognised file to obtain current version from")
 	}
-
-	return "", fmt.Errorf("cannot find version for file %s\n", o.Filename)
+	v, err := handler.Read(o.Dir, o.Filename)
+	if err != nil {
+		return "", err
+	}
+	if v == "" {
+		return "", fmt.Errorf("cannot find version for file %s\n", o.Filename)
+	}
+	return v, nil
 }
 
 func (o *StepNextVersionOptions) getLatestTag() (string, error) {
@@ -361,47 +432,31 @@ func (o *StepNextVersionOptions) getNewVersionFromTagAndFile() (string, error) {
 
 // SetVersion Sets the version...
 func (o *StepNextVersionOptions) SetVersion() error {
-	var err error
-	var matchField string
-	var regex *regexp.Regexp
-	filename := filepath.Join(o.Dir, o.Filename)
-	b, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return err
-	}
-	switch o.Filename {
-	case packagejson:
-		regex = regexp.MustCompile(`[0-9][0-9]{0,2}.[0-9][0-9]{0,2}(.[0-9][0-9]{0,2})?(.[0-9][0-9]{0,2})?(-development)?`)
-		matchField = "\"version\": \""
-
-	case chartyaml:
-		regex = regexp.MustCompile(`[0-9][0-9]{0,2}.[0-9][0-9]{0,2}(.[0-9][0-9]{0,2})?(.[0-9][0-9]{0,2})?(-.*)?`)
-		matchField = "version: "
-
-	default:
-		return fmt.Errorf("unrecognised filename %s, supported files are %s %s", o.Filename, packagejson, chartyaml)
+	handler := o.versionHandler(o.Filename)
+	if handler == nil {
+		return fmt.Errorf("unrecognised filename %s, supported files are %s %s %s %s", o.Filename, packagejson, chartyaml, pomxml, dockerfile)
 	}
-
-	lines := strings.Split(string(b), "\n")
-
-	for i, line := range lines {
-		if strings.Contains(line, matchField) {
-			lines[i] = regex.ReplaceAllString(line, o.NewVersion)
-		} else {
-			lines[i] = line
+	if writer, ok := handler.(multiFileVersionWriter); ok {
+		files, err := writer.WriteFiles(o.Dir, o.Filename, o.NewVersion)
+		if err != nil {
+			return err
 		}
+		return o.commitVersion(files...)
 	}
-	output := strings.Join(lines, "\n")
-	err = ioutil.WriteFile(filename, []byte(output), 0644)
+	err := handler.Write(o.Dir, o.Filename, o.NewVersion)
 	if err != nil {
 		return err
 	}
+	return o.commitVersion(o.Filename)
+}
 
+// commitVersion adds the given files, relative to the dir, and commits the new version unless we are tagging
+func (o *StepNextVersionOptions) commitVersion(files ...string) error {
 	if o.Tag {
 		// lets not commit to git as we do that in the tag step
 		return nil
 	}
-	err = o.Git().Add(o.Dir, o.Filename)
+	err := o.Git().Add(o.Dir, files...)
 	if err != nil {
 		return err
 	}
@@ -428,8 +483,23 @@ func (o *StepNextVersionOptions) setChartVersion(b []byte) error {
 	return nil
 }
 
-func (o *StepNextVersionOptions) setPomVersion(b []byte) error {
-	return nil
+// dockerfileLabelLines returns the indexes of the Dockerfile lines which are part of a LABEL instruction, including
+// any continuation lines of multi-line LABEL instructions
+func dockerfileLabelLines(lines []string) []int {
+	answer := []int{}
+	inLabel := false
+	for i, line := range lines {
+		text := strings.TrimSpace(line)
+		if !inLabel {
+			fields := strings.Fields(text)
+			inLabel = len(fields) > 0 && strings.EqualFold(fields[0], "LABEL")
+		}
+		if inLabel {
+			answer = append(answer, i)
+		}
+		inLabel = inLabel && strings.HasSuffix(text, "\\")
+	}
+	return answer
 }
 
 // returns a string array containing the git owner and repo name for a given URL
@@ -0,0 +1,535 @@
+package step
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/jenkins-x/jx/pkg/log"
+	"github.com/jenkins-x/jx/pkg/util"
+	"github.com/pkg/errors"
+)
+
+// VersionHandler reads and writes the version of a project in a file of a given format
+type VersionHandler interface {
+	// Matches returns true if the handler supports the file name
+	Matches(filename string) bool
+	// Read returns the version in the file in the dir or an empty string if the file does not contain a version
+	Read(dir string, file string) (string, error)
+	// Write replaces the version in the file in the dir
+	Write(dir string, file string, version string) error
+}
+
+// multiFileVersionWriter is implemented by a VersionHandler whose Write modifies other files too, it returns all the
+// modified files relative to the dir
+type multiFileVersionWriter interface {
+	WriteFiles(dir string, file string, version string) ([]string, error)
+}
+
+// multiVersionReader is implemented by a VersionHandler for files which contain several version fields, it returns
+// the values of all of them keyed by the field name
+type multiVersionReader interface {
+	ReadVersions(dir string, file string) (map[string]string, error)
+}
+
+var (
+	versionHandlersLock sync.RWMutex
+	versionHandlers     []VersionHandler
+)
+
+// RegisterVersionHandler registers a VersionHandler for a file format. Registered handlers take precedence over the
+// built in ones and over handlers registered before them. It returns a function which restores the handlers registered
+// before this one, e.g. to clean up after a test
+func RegisterVersionHandler(handler VersionHandler) func() {
+	versionHandlersLock.Lock()
+	defer versionHandlersLock.Unlock()
+	previous := versionHandlers[:len(versionHandlers):len(versionHandlers)]
+	versionHandlers = append(previous, handler)
+	return func() {
+		versionHandlersLock.Lock()
+		defer versionHandlersLock.Unlock()
+		versionHandlers = previous
+	}
+}
+
+// versionHandler returns the VersionHandler for the file name or nil if the file format is not supported
+func (o *StepNextVersionOptions) versionHandler(filename string) VersionHandler {
+	versionHandlersLock.RLock()
+	defer versionHandlersLock.RUnlock()
+	for i := len(versionHandlers) - 1; i >= 0; i-- {
+		if versionHandlers[i].Matches(filename) {
+			return versionHandlers[i]
+		}
+	}
+	if o.YAMLPath != "" {
+		return &yamlPathVersionHandler{path: o.YAMLPath}
+	}
+	builtin := []VersionHandler{
+		&chartVersionHandler{field: o.ChartField},
+		packageJSONVersionHandler{},
+		pomVersionHandler{},
+		makefileVersionHandler{},
+		dockerfileVersionHandler{},
+	}
+	for _, handler := range builtin {
+		if handler.Matches(filename) {
+			return handler
+		}
+	}
+	return nil
+}
+
+// chartVersionHandler handles the version or appVersion field of a Chart.yaml
+type chartVersionHandler struct {
+	field string
+}
+
+// Matches returns true for a Chart.yaml
+func (h *chartVersionHandler) Matches(filename string) bool {
+	return filename == chartyaml
+}
+
+// Read returns the value of the top level version field
+func (h *chartVersionHandler) Read(dir string, file string) (string, error) {
+	chart, err := ioutil.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return "", err
+	}
+
+	log.Logger().Debugf("Found Chart.yaml")
+	field, err := h.chartField()
+	if err != nil {
+		return "", err
+	}
+	scanner := bufio.NewScanner(strings.NewReader(string(chart)))
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), field+":") {
+			v := strings.Trim(strings.TrimSpace(strings.TrimPrefix(scanner.Text(), field+":")), `"'`)
+			if v != "" {
+				log.Logger().Debugf("existing Chart %s %v", field, v)
+				return v, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// Write replaces the value of the top level version field leaving the other version fields intact
+func (h *chartVersionHandler) Write(dir string, file string, version string) error {
+	filename := filepath.Join(dir, file)
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	field, err := h.chartField()
+	if err != nil {
+		return err
+	}
+	regex := regexp.MustCompile(`v?[0-9][0-9]{0,2}.[0-9][0-9]{0,2}(.[0-9][0-9]{0,2})?(.[0-9][0-9]{0,2})?(-[^"']*)?`)
+	prefix := field + ":"
+	lines := strings.Split(string(b), "\n")
+	for i, line := range lines {
+		// only the top level field is replaced so that the other version fields are left intact
+		if strings.HasPrefix(line, prefix) {
+			lines[i] = prefix + regex.ReplaceAllString(strings.TrimPrefix(line, prefix), version)
+		}
+	}
+	return ioutil.WriteFile(filename, []byte(strings.Join(lines, "\n")), 0644)
+}
+
+// ReadVersions returns the values of both the version and appVersion fields which are present
+func (h *chartVersionHandler) ReadVersions(dir string, file string) (map[string]string, error) {
+	answer := map[string]string{}
+	for _, field := range []string{chartFieldVersion, chartFieldAppVersion} {
+		v, err := (&chartVersionHandler{field: field}).Read(dir, file)
+		if err != nil {
+			return nil, err
+		}
+		if v != "" {
+			answer[field] = v
+		}
+	}
+	return answer, nil
+}
+
+// chartField returns the Chart.yaml field containing the version, defaulting to the version of the chart
+func (h *chartVersionHandler) chartField() (string, error) {
+	switch h.field {
+	case "":
+		return chartFieldVersion, nil
+	case chartFieldVersion, chartFieldAppVersion:
+		return h.field, nil
+	default:
+		return "", fmt.Errorf("unsupported chart field %s, supported fields are %s and %s", h.field, chartFieldVersion, chartFieldAppVersion)
+	}
+}
+
+// packageJSONVersionHandler handles the version of a package.json
+type packageJSONVersionHandler struct{}
+
+// Matches returns true for a package.json
+func (packageJSONVersionHandler) Matches(filename string) bool {
+	return filename == packagejson
+}
+
+// Read returns the version property
+func (packageJSONVersionHandler) Read(dir string, file string) (string, error) {
+	p, err := ioutil.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return "", err
+	}
+
+	log.Logger().Debugf("found %s", packagejson)
+
+	var jsPackage PackageJSON
+	json.Unmarshal(p, &jsPackage)
+
+	if jsPackage.Version != "" {
+		log.Logger().Debugf("existing version %s", jsPackage.Version)
+	}
+	return jsPackage.Version, nil
+}
+
+// Write replaces the value of the version property
+func (packageJSONVersionHandler) Write(dir string, file string, version string) error {
+	regex := regexp.MustCompile(`v?[0-9][0-9]{0,2}.[0-9][0-9]{0,2}(.[0-9][0-9]{0,2})?(.[0-9][0-9]{0,2})?(-development)?`)
+	return replaceMatchingLines(filepath.Join(dir, file), "\"version\": \"", regex, version)
+}
+
+// pomVersionHandler handles the project version of a pom.xml and the parent version of its modules
+type pomVersionHandler struct{}
+
+// Matches returns true for a pom.xml
+func (pomVersionHandler) Matches(filename string) bool {
+	return filename == pomxml
+}
+
+// Read returns the project version
+func (pomVersionHandler) Read(dir string, file string) (string, error) {
+	p, err := ioutil.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return "", err
+	}
+
+	log.Logger().Debugf("found pom.xml")
+	var project Project
+	xml.Unmarshal(p, &project)
+	if project.Version != "" {
+		log.Logger().Debugf("existing version %s", project.Version)
+	}
+	return project.Version, nil
+}
+
+// Write replaces the project version and the parent version of the modules
+func (h pomVersionHandler) Write(dir string, file string, version string) error {
+	_, err := h.WriteFiles(dir, file, version)
+	return err
+}
+
+// WriteFiles replaces the project version and the parent version of the modules returning the modified files
+func (pomVersionHandler) WriteFiles(dir string, file string, version string) ([]string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return nil, err
+	}
+	return setPomVersion(dir, dir, b, version, []string{"project", "version"})
+}
+
+// makefileVersionHandler reads the VERSION variable of a Makefile
+type makefileVersionHandler struct{}
+
+// Matches returns true for a Makefile
+func (makefileVersionHandler) Matches(filename string) bool {
+	return filename == makefile
+}
+
+// Read returns the value of the VERSION variable
+func (makefileVersionHandler) Read(dir string, file string) (string, error) {
+	m, err := ioutil.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return "", err
+	}
+
+	log.Logger().Debugf("found Makefile")
+	scanner := bufio.NewScanner(strings.NewReader(string(m)))
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "VERSION") || strings.HasPrefix(scanner.Text(), "VERSION ") || strings.HasPrefix(scanner.Text(), "VERSION:") || strings.HasPrefix(scanner.Text(), "VERSION=") {
+			parts := strings.Split(scanner.Text(), "=")
+
+			v := strings.TrimSpace(parts[1])
+			if v != "" {
+				log.Logger().Debugf("existing Makefile version %s", v)
+				return v, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// Write is not supported for a Makefile
+func (makefileVersionHandler) Write(dir string, file string, version string) error {
+	return fmt.Errorf("unrecognised filename %s, supported files are %s %s %s %s", file, packagejson, chartyaml, pomxml, dockerfile)
+}
+
+// dockerfileVersionHandler handles the version label of a Dockerfile
+type dockerfileVersionHandler struct{}
+
+// Matches returns true for a Dockerfile
+func (dockerfileVersionHandler) Matches(filename string) bool {
+	return filename == dockerfile
+}
+
+// Read returns the value of the version label
+func (dockerfileVersionHandler) Read(dir string, file string) (string, error) {
+	d, err := ioutil.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return "", err
+	}
+
+	log.Logger().Debugf("found Dockerfile")
+	lines := strings.Split(string(d), "\n")
+	for _, i := range dockerfileLabelLines(lines) {
+		match := dockerVersionLabelRegex.FindStringSubmatch(lines[i])
+		if len(match) > 3 && match[3] != "" {
+			log.Logger().Debugf("existing Dockerfile version %s", match[3])
+			return match[3], nil
+		}
+	}
+	return "", nil
+}
+
+// Write replaces the value of the version label
+func (dockerfileVersionHandler) Write(dir string, file string, version string) error {
+	filename := filepath.Join(dir, file)
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(b), "\n")
+	for _, i := range dockerfileLabelLines(lines) {
+		lines[i] = dockerVersionLabelRegex.ReplaceAllString(lines[i], "${1}${2}"+version)
+	}
+	return ioutil.WriteFile(filename, []byte(strings.Join(lines, "\n")), 0644)
+}
+
+// yamlPathVersionHandler handles the scalar value at a dotted path such as image.tag in a YAML file such as the
+// values.yaml of a chart
+type yamlPathVersionHandler struct {
+	path string
+}
+
+// Matches returns true for a YAML file
+func (h *yamlPathVersionHandler) Matches(filename string) bool {
+	ext := filepath.Ext(filename)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// Read returns the value at the path, failing if the path does not exist
+func (h *yamlPathVersionHandler) Read(dir string, file string) (string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(string(b), "\n")
+	scalar, err := findYAMLScalar(lines, h.path)
+	if err != nil {
+		return "", errors.Wrapf(err, "reading %s", file)
+	}
+	v := lines[scalar.line][scalar.start:scalar.end]
+	log.Logger().Debugf("existing %s %s in %s", h.path, v, file)
+	return v, nil
+}
+
+// Write replaces the value at the path leaving the rest of the file, including any quotes and comments, intact
+func (h *yamlPathVersionHandler) Write(dir string, file string, version string) error {
+	filename := filepath.Join(dir, file)
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(b), "\n")
+	scalar, err := findYAMLScalar(lines, h.path)
+	if err != nil {
+		return errors.Wrapf(err, "writing %s", file)
+	}
+	line := lines[scalar.line]
+	lines[scalar.line] = line[:scalar.start] + version + line[scalar.end:]
+	return ioutil.WriteFile(filename, []byte(strings.Join(lines, "\n")), 0644)
+}
+
+// yamlKeyRegex matches a line containing a mapping key capturing the indentation, the key and the rest of the line
+var yamlKeyRegex = regexp.MustCompile(`^( *)("[^"]*"|'[^']*'|[^\s#'"-][^:#]*?)\s*:(\s+(.*))?$`)
+
+// yamlCommentRegex matches a trailing comment
+var yamlCommentRegex = regexp.MustCompile(`\s+#`)
+
+// yamlScalar the location of the text of a scalar value in the lines of a YAML document
+type yamlScalar struct {
+	line  int
+	start int
+	end   int
+}
+
+// findYAMLScalar finds the text of the scalar value at the dotted path of mapping keys in the lines of a block style
+// YAML document, the quotes and any trailing comment are excluded from the returned location
+func findYAMLScalar(lines []string, path string) (*yamlScalar, error) {
+	keys := strings.Split(path, ".")
+	depth := 0
+	parentIndent := -1
+	childIndent := -1
+	for i, line := range lines {
+		text := strings.TrimSpace(line)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if indent <= parentIndent {
+			// we have left the mapping of the parent key
+			break
+		}
+		if childIndent < 0 {
+			childIndent = indent
+		}
+		if indent != childIndent {
+			continue
+		}
+		m := yamlKeyRegex.FindStringSubmatchIndex(line)
+		if m == nil || strings.Trim(line[m[4]:m[5]], `"'`) != keys[depth] {
+			continue
+		}
+		if depth < len(keys)-1 {
+			depth++
+			parentIndent = indent
+			childIndent = -1
+			continue
+		}
+
+		if m[8] < 0 || m[8] == m[9] {
+			return nil, fmt.Errorf("the value of %s is not a scalar", path)
+		}
+		start, end := m[8], m[9]
+		value := line[start:end]
+		switch value[0] {
+		case '"', '\'':
+			closing := strings.IndexByte(value[1:], value[0])
+			if closing < 0 {
+				return nil, fmt.Errorf("the value of %s has no closing quote", path)
+			}
+			return &yamlScalar{line: i, start: start + 1, end: start + 1 + closing}, nil
+		case '|', '>', '{', '[', '&', '*':
+			return nil, fmt.Errorf("the value of %s is not a plain scalar", path)
+		}
+		if loc := yamlCommentRegex.FindStringIndex(value); loc != nil {
+			end = start + loc[0]
+		}
+		return &yamlScalar{line: i, start: start, end: start + len(strings.TrimRight(line[start:end], " \t"))}, nil
+	}
+	return nil, fmt.Errorf("no %s found", path)
+}
+
+// setPomVersion updates the element at the given path in the pom.xml in the given dir to the new version then
+// recursively updates the parent version of each of its modules, returning the modified files relative to the root
+// dir. Other version elements such as those of dependencies are left untouched
+func setPomVersion(rootDir string, dir string, b []byte, version string, path []string) ([]string, error) {
+	pomFile := filepath.Join(dir, pomxml)
+	updated, found, err := replaceXMLElementText(b, version, path...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", pomFile)
+	}
+	if !found {
+		return nil, fmt.Errorf("no <%s> element found in %s", strings.Join(path, "><"), pomFile)
+	}
+	err = ioutil.WriteFile(pomFile, updated, 0644)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(rootDir, pomFile)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{rel}
+
+	var project Project
+	err = xml.Unmarshal(b, &project)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", pomFile)
+	}
+	for _, module := range project.Modules {
+		moduleDir := filepath.Join(dir, strings.TrimSpace(module))
+		m, err := ioutil.ReadFile(filepath.Join(moduleDir, pomxml))
+		if err != nil {
+			return nil, err
+		}
+		moduleFiles, err := setPomVersion(rootDir, moduleDir, m, version, []string{"project", "parent", "version"})
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, moduleFiles...)
+	}
+	return files, nil
+}
+
+// replaceXMLElementText replaces the text of the first element found at the given path of element names from the
+// document root, leaving the rest of the document untouched
+func replaceXMLElementText(data []byte, value string, path ...string) ([]byte, bool, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	elements := []string{}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return data, false, nil
+		}
+		if err != nil {
+			return data, false, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			elements = append(elements, t.Name.Local)
+			if util.StringArraysEqual(elements, path) {
+				start := decoder.InputOffset()
+				end := start
+				token, err = decoder.Token()
+				if err != nil {
+					return data, false, err
+				}
+				if _, ok := token.(xml.CharData); ok {
+					end = decoder.InputOffset()
+				}
+				answer := append([]byte{}, data[:start]...)
+				answer = append(answer, value...)
+				answer = append(answer, data[end:]...)
+				return answer, true, nil
+			}
+		case xml.EndElement:
+			elements = elements[:len(elements)-1]
+		}
+	}
+}
+
+// replaceMatchingLines replaces the matches of the regex with the version on the lines of the file containing the
+// match field
+func replaceMatchingLines(filename string, matchField string, regex *regexp.Regexp, version string) error {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(b), "\n")
+
+	for i, line := range lines {
+		if strings.Contains(line, matchField) {
+			lines[i] = regex.ReplaceAllString(line, version)
+		} else {
+			lines[i] = line
+		}
+	}
+	output := strings.Join(lines, "\n")
+	return ioutil.WriteFile(filename, []byte(output), 0644)
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/jenkins-x/jx/pkg/tests"
 	"github.com/jenkins-x/jx/pkg/util"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSetVersionJavascript(t *testing.T) {
@@ -113,6 +114,171 @@ func TestSetVersionChart(t *testing.T) {
 	assert.Equal(t, string(testFile), string(updatedFile), "replaced version")
 }
 
+func TestSetVersionChartField(t *testing.T) {
+	testData := path.Join("test_data", "next_version", "helm-app-version")
+
+	for field, expected := range map[string]string{
+		"version":    "expected_Chart.yaml",
+		"appVersion": "expected_appVersion_Chart.yaml",
+	} {
+		o := setVersionInCopy(t, testData, "Chart.yaml", func(o *step.StepNextVersionOptions) {
+			o.ChartField = field
+		})
+		defer os.RemoveAll(o.Dir)
+
+		assertFileMatches(t, testData, expected, o.Dir, o.Filename)
+	}
+}
+
+func TestSetVersionMavenMultiModule(t *testing.T) {
+	testData := path.Join("test_data", "next_version", "maven-multimodule")
+	o := setVersionInCopy(t, testData, "pom.xml", nil)
+	defer os.RemoveAll(o.Dir)
+
+	for _, dir := range []string{"", "module-a", "module-b"} {
+		assertFileMatches(t, path.Join(testData, dir), "expected_pom.xml", path.Join(o.Dir, dir), "pom.xml")
+	}
+}
+
+func TestSetVersionDockerfile(t *testing.T) {
+	for _, dir := range []string{"docker", path.Join("docker", "multi-label")} {
+		testData := path.Join("test_data", "next_version", dir)
+		o := setVersionInCopy(t, testData, "Dockerfile", nil)
+		defer os.RemoveAll(o.Dir)
+
+		assertFileMatches(t, testData, "expected_Dockerfile", o.Dir, o.Filename)
+	}
+}
+
+func TestSetVersionValuesYAMLPath(t *testing.T) {
+	testData := path.Join("test_data", "next_version", "values")
+	o := setVersionInCopy(t, testData, "values.yaml", func(o *step.StepNextVersionOptions) {
+		o.YAMLPath = "image.tag"
+	})
+	defer os.RemoveAll(o.Dir)
+
+	assertFileMatches(t, testData, "expected_values.yaml", o.Dir, o.Filename)
+}
+
+func TestRunPreservesVersionPrefix(t *testing.T) {
+	testData := path.Join("test_data", "next_version", "prefixed")
+
+	testCases := []struct {
+		name            string
+		tagPrefix       string
+		expectedVersion string
+	}{
+		{"preserves the file prefix", "", "v1.2.3"},
+		{"overrides the file prefix", "none", "1.2.3"},
+		{"custom prefix", "release-", "release-1.2.3"},
+	}
+
+	for _, tt := range testCases {
+		o := nextVersionOptionsInCopy(t, testData, "Chart.yaml")
+		defer os.RemoveAll(o.Dir)
+		o.TagPrefix = tt.tagPrefix
+
+		err := o.Run()
+		assert.NoError(t, err, tt.name)
+		os.Remove("VERSION")
+
+		assert.Equal(t, tt.expectedVersion, o.NewVersion, tt.name)
+		updatedFile, err := util.LoadBytes(o.Dir, o.Filename)
+		assert.NoError(t, err, tt.name)
+		assert.Contains(t, string(updatedFile), "version: "+tt.expectedVersion+"\n", tt.name)
+	}
+
+	o := nextVersionOptionsInCopy(t, path.Join("test_data", "next_version", "helm"), "Chart.yaml")
+	defer os.RemoveAll(o.Dir)
+
+	err := o.Run()
+	assert.NoError(t, err)
+	os.Remove("VERSION")
+	assert.Equal(t, "1.2.3", o.NewVersion, "unprefixed files should stay unprefixed")
+}
+
+func TestRunPinnedVersion(t *testing.T) {
+	testCases := []struct {
+		name          string
+		pinnedVersion string
+		scheme        string
+		wantErr       bool
+	}{
+		{"semver", "2.0.0", "semver", false},
+		{"calver", "2020.02.1", "calver", false},
+		{"invalid semver", "2020.02.1", "semver", true},
+		{"invalid calver", "2.0", "calver", true},
+		{"unknown scheme", "2.0.0", "romver", true},
+	}
+
+	for _, tt := range testCases {
+		o := nextVersionOptionsInCopy(t, path.Join("test_data", "next_version", "helm"), "Chart.yaml")
+		defer os.RemoveAll(o.Dir)
+		o.NewVersion = ""
+		o.PinnedVersion = tt.pinnedVersion
+		o.VersionScheme = tt.scheme
+
+		err := o.Run()
+		os.Remove("VERSION")
+		if tt.wantErr {
+			assert.Error(t, err, tt.name)
+			continue
+		}
+		assert.NoError(t, err, tt.name)
+
+		assert.Equal(t, tt.pinnedVersion, o.NewVersion, tt.name)
+		updatedFile, err := util.LoadBytes(o.Dir, o.Filename)
+		assert.NoError(t, err, tt.name)
+		assert.Contains(t, string(updatedFile), "version: "+tt.pinnedVersion+"\n", tt.name)
+	}
+}
+
+// nextVersionOptionsInCopy copies the test data into a temporary directory, which the caller should remove, and
+// returns the options to set the version 1.2.3 in the file of the copy
+func nextVersionOptionsInCopy(t *testing.T, testData string, filename string) *step.StepNextVersionOptions {
+	f, err := ioutil.TempDir("", "test-set-version")
+	require.NoError(t, err)
+
+	_, err = os.Stat(testData)
+	require.NoError(t, err)
+	err = util.CopyDir(testData, f, true)
+	require.NoError(t, err)
+
+	o := &step.StepNextVersionOptions{
+		StepOptions: step2.StepOptions{
+			CommonOptions: &opts.CommonOptions{},
+		},
+	}
+	o.Out = tests.Output()
+	o.Dir = f
+	o.Filename = filename
+	o.NewVersion = "1.2.3"
+	o.SetGit(&gits.GitFake{})
+	return o
+}
+
+// setVersionInCopy sets the version 1.2.3 in the file of a copy of the test data, the options can be modified by the
+// mutate function before the version is set
+func setVersionInCopy(t *testing.T, testData string, filename string, mutate func(*step.StepNextVersionOptions)) *step.StepNextVersionOptions {
+	o := nextVersionOptionsInCopy(t, testData, filename)
+	if mutate != nil {
+		mutate(o)
+	}
+	err := o.SetVersion()
+	assert.NoError(t, err)
+	return o
+}
+
+// assertFileMatches asserts that the updated file is the same as the expected file of the test data
+func assertFileMatches(t *testing.T, testData string, expected string, dir string, filename string) {
+	updatedFile, err := util.LoadBytes(dir, filename)
+	assert.NoError(t, err)
+	testFile, err := util.LoadBytes(testData, expected)
+	assert.NoError(t, err)
+
+	assert.Equal(t, string(testFile), string(updatedFile), "replaced version in %s", path.Join(dir, filename))
+}
+
 func TestRunChartsDir(t *testing.T) {
 	originalJxHome, tempJxHome, err := testhelpers.CreateTestJxHomeDir()
 	assert.NoError(t, err)
@@ -3,6 +3,10 @@
 package step_test
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	step2 "github.com/jenkins-x/jx/pkg/cmd/opts/step"
@@ -10,6 +14,7 @@ import (
 
 	"github.com/jenkins-x/jx/pkg/cmd/opts"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMakefile(t *testing.T) {
@@ -62,3 +67,197 @@ func TestChart(t *testing.T) {
 
 	assert.Equal(t, "0.0.1-SNAPSHOT", v, "error with GetVersion for a Chart.yaml")
 }
+
+func TestPomXMLMultiModule(t *testing.T) {
+	t.Parallel()
+	o := step.StepNextVersionOptions{
+		StepOptions: step2.StepOptions{
+			CommonOptions: &opts.CommonOptions{},
+		},
+		Dir:      "test_data/next_version/maven-multimodule",
+		Filename: "pom.xml",
+	}
+
+	v, err := o.GetVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.0-SNAPSHOT", v, "error with GetVersion for a multi-module pom.xml")
+}
+
+func TestMakefileWithVersionPrefix(t *testing.T) {
+	t.Parallel()
+	o := step.StepNextVersionOptions{
+		StepOptions: step2.StepOptions{
+			CommonOptions: &opts.CommonOptions{},
+		},
+		Dir:      "test_data/next_version/prefixed",
+		Filename: "Makefile",
+	}
+
+	v, err := o.GetVersion()
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.2.0", v, "error with GetVersion for a Makefile with a prefixed version")
+}
+
+func TestDockerfile(t *testing.T) {
+	t.Parallel()
+	for dir, expected := range map[string]string{
+		"test_data/next_version/docker":             "1.0.4",
+		"test_data/next_version/docker/multi-label": "2.1.0",
+	} {
+		o := step.StepNextVersionOptions{
+			StepOptions: step2.StepOptions{
+				CommonOptions: &opts.CommonOptions{},
+			},
+			Dir:      dir,
+			Filename: "Dockerfile",
+		}
+
+		v, err := o.GetVersion()
+
+		assert.NoError(t, err)
+
+		assert.Equal(t, expected, v, "error with GetVersion for the Dockerfile in %s", dir)
+	}
+}
+
+func TestChartField(t *testing.T) {
+	t.Parallel()
+	for field, expected := range map[string]string{
+		"version":    "0.1.0",
+		"appVersion": "1.0.0",
+	} {
+		o := step.StepNextVersionOptions{
+			StepOptions: step2.StepOptions{
+				CommonOptions: &opts.CommonOptions{},
+			},
+			Dir:        "test_data/next_version/helm-app-version",
+			Filename:   "Chart.yaml",
+			ChartField: field,
+		}
+
+		v, err := o.GetVersion()
+
+		assert.NoError(t, err)
+
+		assert.Equal(t, expected, v, "error with GetVersion for the %s of a Chart.yaml", field)
+	}
+}
+
+func TestGetVersions(t *testing.T) {
+	t.Parallel()
+	for filename, expected := range map[string]map[string]string{
+		"helm-app-version/Chart.yaml": {
+			"version":    "0.1.0",
+			"appVersion": "1.0.0",
+		},
+		"java/pom.xml": {
+			"version": "1.0-SNAPSHOT",
+		},
+	} {
+		o := step.StepNextVersionOptions{
+			StepOptions: step2.StepOptions{
+				CommonOptions: &opts.CommonOptions{},
+			},
+			Dir:      filepath.Join("test_data/next_version", filepath.Dir(filename)),
+			Filename: filepath.Base(filename),
+		}
+
+		versions, err := o.GetVersions()
+
+		assert.NoError(t, err)
+
+		assert.Equal(t, expected, versions, "error with GetVersions for %s", filename)
+	}
+}
+
+func TestValuesYAMLPath(t *testing.T) {
+	t.Parallel()
+	for yamlPath, expected := range map[string]string{
+		"image.tag":         "0.0.1",
+		"sidecar.image.tag": "2.1.0",
+	} {
+		o := step.StepNextVersionOptions{
+			StepOptions: step2.StepOptions{
+				CommonOptions: &opts.CommonOptions{},
+			},
+			Dir:      "test_data/next_version/values",
+			Filename: "values.yaml",
+			YAMLPath: yamlPath,
+		}
+
+		v, err := o.GetVersion()
+
+		assert.NoError(t, err)
+
+		assert.Equal(t, expected, v, "error with GetVersion for %s of a values.yaml", yamlPath)
+	}
+
+	for _, yamlPath := range []string{"image.digest", "tag", "image"} {
+		o := step.StepNextVersionOptions{
+			StepOptions: step2.StepOptions{
+				CommonOptions: &opts.CommonOptions{},
+			},
+			Dir:      "test_data/next_version/values",
+			Filename: "values.yaml",
+			YAMLPath: yamlPath,
+		}
+
+		_, err := o.GetVersion()
+
+		assert.Error(t, err, "GetVersion should fail for %s of a values.yaml", yamlPath)
+	}
+}
+
+type propertiesVersionHandler struct{}
+
+func (propertiesVersionHandler) Matches(filename string) bool {
+	return filename == "version.properties"
+}
+
+func (propertiesVersionHandler) Read(dir string, file string) (string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(strings.TrimPrefix(string(b), "version=")), nil
+}
+
+func (propertiesVersionHandler) Write(dir string, file string, version string) error {
+	return ioutil.WriteFile(filepath.Join(dir, file), []byte("version="+version+"\n"), 0644)
+}
+
+func TestCustomVersionHandler(t *testing.T) {
+	restore := step.RegisterVersionHandler(propertiesVersionHandler{})
+	defer restore()
+
+	dir, err := ioutil.TempDir("", "test-custom-version-handler")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "version.properties"), []byte("version=1.0.0\n"), 0644)
+	require.NoError(t, err)
+
+	o := step.StepNextVersionOptions{
+		StepOptions: step2.StepOptions{
+			CommonOptions: &opts.CommonOptions{},
+		},
+		Dir:        dir,
+		Filename:   "version.properties",
+		NewVersion: "1.2.3",
+		Tag:        true,
+	}
+
+	v, err := o.GetVersion()
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", v)
+
+	err = o.SetVersion()
+	require.NoError(t, err)
+
+	v, err = o.GetVersion()
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.3", v)
+}
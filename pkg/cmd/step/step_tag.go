@@ -44,12 +44,13 @@ type StepTagFlags struct {
 	Dir                  string
 	ChartsDir            string
 	ChartValueRepository string
+	TagPrefix            string
 	NoApply              bool
 }
 
 var (
 	stepTagLong = templates.LongDesc(`
-		This pipeline step command creates a git tag using a version number prefixed with 'v' and pushes it to a
+		This pipeline step command creates a git tag using a version number prefixed with 'v', or the --tag-prefix, and pushes it to a
 		remote origin repo.
 
 		This commands effectively runs:
@@ -93,11 +94,24 @@ func NewCmdStepTag(commonOpts *opts.CommonOptions) *cobra.Command {
 	cmd.Flags().StringVarP(&options.Flags.Dir, "dir", "", "", "the directory which may contain a 'jenkins-x.yml'")
 	cmd.Flags().StringVarP(&options.Flags.ChartValueRepository, "charts-value-repository", "r", "", "the fully qualified image name without the version tag. e.g. 'dockerregistry/myorg/myapp'")
 
+	cmd.Flags().StringVarP(&options.Flags.TagPrefix, "tag-prefix", "", "", fmt.Sprintf("the prefix of the git tag, defaults to 'v'. Use '%s' for no prefix", versionPrefixNone))
 	cmd.Flags().BoolVarP(&options.Flags.NoApply, "no-apply", "", false, "Do not push the tag to the server, this is used for example in dry runs")
 
 	return cmd
 }
 
+// tagName returns the name of the git tag of the version, prefixed with 'v' unless another prefix is specified
+func (o *StepTagOptions) tagName() string {
+	switch o.Flags.TagPrefix {
+	case "":
+		return "v" + o.Flags.Version
+	case versionPrefixNone:
+		return o.Flags.Version
+	default:
+		return o.Flags.TagPrefix + o.Flags.Version
+	}
+}
+
 func (o *StepTagOptions) Run() error {
 	if o.Flags.Version == "" {
 		// lets see if its defined in the VERSION file
@@ -139,7 +153,7 @@ func (o *StepTagOptions) Run() error {
 		return err
 	}
 
-	tag := "v" + o.Flags.Version
+	tag := o.tagName()
 	log.Logger().Debugf("performing git commit")
 	err = o.Git().AddCommit("", fmt.Sprintf("release %s", o.Flags.Version))
 	if err != nil {
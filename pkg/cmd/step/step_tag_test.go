@@ -131,3 +131,24 @@ func TestDefaultChartValueRepositoryValidName(t *testing.T) {
 
 	assert.Equal(t, expectedImageName, o.defaultChartValueRepository())
 }
+
+func TestStepTagName(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		prefix   string
+		expected string
+	}{
+		{prefix: "", expected: "v1.2.3"},
+		{prefix: versionPrefixNone, expected: "1.2.3"},
+		{prefix: "release-", expected: "release-1.2.3"},
+	}
+	for _, tt := range tests {
+		o := StepTagOptions{
+			Flags: StepTagFlags{
+				Version:   "1.2.3",
+				TagPrefix: tt.prefix,
+			},
+		}
+		assert.Equal(t, tt.expected, o.tagName(), "tag name for prefix %q", tt.prefix)
+	}
+}
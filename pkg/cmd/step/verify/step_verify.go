@@ -39,7 +39,9 @@ func NewCmdStepVerify(commonOpts *opts.CommonOptions) *cobra.Command {
 	cmd.AddCommand(NewCmdStepVerifyPackages(commonOpts))
 	cmd.AddCommand(NewCmdStepVerifyPod(commonOpts))
 	cmd.AddCommand(NewCmdStepVerifyPreInstall(commonOpts))
+	cmd.AddCommand(NewCmdStepVerifyRemoteEnv(commonOpts))
 	cmd.AddCommand(NewCmdStepVerifyRequirements(commonOpts))
+	cmd.AddCommand(NewCmdStepVerifyTLS(commonOpts))
 	cmd.AddCommand(NewCmdStepVerifyURL(commonOpts))
 	cmd.AddCommand(NewCmdStepVerifyValues(commonOpts))
 
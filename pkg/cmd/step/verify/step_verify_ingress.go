@@ -1,9 +1,15 @@
 package verify
 
 import (
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/mail"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/jenkins-x/jx/pkg/cmd/opts/step"
@@ -12,6 +18,7 @@ import (
 	"github.com/jenkins-x/jx/pkg/cloud/gke/externaldns"
 	"github.com/jenkins-x/jx/pkg/config"
 	"github.com/jenkins-x/jx/pkg/kube"
+	"github.com/jenkins-x/jx/pkg/kube/pki"
 	"github.com/jenkins-x/jx/pkg/util"
 
 	"github.com/jenkins-x/jx/pkg/cloud"
@@ -22,7 +29,10 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	pipelineapi "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -35,21 +45,70 @@ var (
 		# populate the ingress domain if not using a configured 'ingress.domain' setting
 		jx step verify ingress
 
+		# write the discovered ingress configuration to a separate file leaving jx-requirements.yml untouched
+		jx step verify ingress --output-requirements discovered-requirements.yml
+
+		# verify the requirements of an environment checked out in another directory
+		jx step verify ingress --requirements-file ../environment-staging/jx-requirements.yml
+
+		# use the external IP recorded by a bare metal load balancer in a ConfigMap
+		jx step verify ingress --external-ip-configmap jx-ingress-config --external-ip-configmap-key externalIP
+
+		# fail if the ingress domain is an automatic DNS domain such as nip.io rather than a custom domain
+		jx step verify ingress --require-custom-domain
+
+		# export the discovered ingress configuration as environment variables for later pipeline steps
+		jx step verify ingress --export-env --export-env-file ingress.env
+
 			`)
 )
 
+const (
+	defaultIngressWaitTimeout  = time.Minute * 5
+	defaultIngressPollInterval = time.Second * 3
+
+	// exportEnvFileEnvVar the environment variable containing the default file to export the environment variables to
+	exportEnvFileEnvVar = "JX_ENV_FILE"
+
+	// defaultRegistryService the name of the Service of the in cluster container registry installed by Jenkins X
+	defaultRegistryService = "docker-registry"
+)
+
+// dnsProviderCredentialsSecrets the DNS-01 providers supported by cert-manager and the default names of the secrets
+// containing their credentials
+var dnsProviderCredentialsSecrets = map[string]string{
+	"acmedns":      "acmedns-credentials",
+	"azuredns":     "azuredns-credentials",
+	"clouddns":     "clouddns-credentials",
+	"cloudflare":   "cloudflare-credentials",
+	"digitalocean": "digitalocean-credentials",
+	"route53":      "route53-credentials",
+}
+
 // StepVerifyIngressOptions contains the command line flags
 type StepVerifyIngressOptions struct {
 	step.StepOptions
 
-	Dir              string
-	Namespace        string
-	Provider         string
-	IngressNamespace string
-	IngressService   string
-	ExternalIP       string
-	LazyCreate       bool
-	LazyCreateFlag   string
+	Dir                     string
+	Namespace               string
+	Provider                string
+	IngressNamespace        string
+	IngressService          string
+	ExternalIP              string
+	LazyCreate              bool
+	LazyCreateFlag          string
+	IngressWaitTimeout      time.Duration
+	IngressPollInterval     time.Duration
+	CheckController         bool
+	OutputRequirements      string
+	RequirementsFile        string
+	DryRun                  bool
+	RequireCustomDomain     bool
+	Strict                  bool
+	ExportEnv               bool
+	ExportEnvFile           string
+	RegistryService         string
+	IngressHostnameOverride string
 }
 
 // StepVerifyIngressResults stores the generated results
@@ -83,10 +142,25 @@ func NewCmdStepVerifyIngress(commonOpts *opts.CommonOptions) *cobra.Command {
 	cmd.Flags().StringVarP(&options.Dir, "dir", "d", ".", "the directory to look for the values.yaml file")
 	cmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "the namespace to install into. Defaults to $DEPLOY_NAMESPACE if not")
 
-	cmd.Flags().StringVarP(&options.IngressNamespace, "ingress-namespace", "", opts.DefaultIngressNamesapce, "The namespace for the Ingress controller")
-	cmd.Flags().StringVarP(&options.IngressService, "ingress-service", "", opts.DefaultIngressServiceName, "The name of the Ingress controller Service")
+	cmd.Flags().StringVarP(&options.IngressNamespace, "ingress-namespace", "", "", "The namespace for the Ingress controller. Defaults to the namespace of the default Ingress controller of the provider")
+	cmd.Flags().StringVarP(&options.IngressService, "ingress-service", "", "", "The name of the Ingress controller Service, optionally in the form namespace/name. Defaults to the Service of the default Ingress controller of the provider")
+	cmd.Flags().StringVarP(&options.IngressHostnameOverride, "ingress-hostname-override", "", "", "The external host name used to access ingress endpoints, e.g. of a reverse proxy in front of the cluster. If specified the domain is derived from it rather than discovered from the Ingress controller Service")
 	cmd.Flags().StringVarP(&options.ExternalIP, "external-ip", "", "", "The external IP used to access ingress endpoints from outside the Kubernetes cluster. For bare metal on premise clusters this is often the IP of the Kubernetes master. For cloud installations this is often the external IP of the ingress LoadBalancer.")
+	cmd.Flags().StringVarP(&options.ExternalIPConfigMap, "external-ip-configmap", "", "", "The name of a ConfigMap in the Ingress controller namespace containing the external IP of the Ingress controller, e.g. when using MetalLB on bare metal clusters")
+	cmd.Flags().StringVarP(&options.ExternalIPConfigMapKey, "external-ip-configmap-key", "", opts.DefaultExternalIPConfigMapKey, "The key of the external IP in the ConfigMap specified via --external-ip-configmap")
+	cmd.Flags().BoolVarP(&options.PlanOnly, "plan", "", false, "Logs the DNS records which would be registered for the domain with the provider rather than registering them")
 	cmd.Flags().StringVarP(&options.Provider, "provider", "", "", "Cloud service providing the Kubernetes cluster.  Supported providers: "+cloud.KubernetesProviderOptions())
+	cmd.Flags().DurationVarP(&options.IngressWaitTimeout, "ingress-wait-timeout", "", defaultIngressWaitTimeout, "The maximum duration to wait for the Ingress controller Service to have an external host")
+	cmd.Flags().DurationVarP(&options.IngressPollInterval, "ingress-poll-interval", "", defaultIngressPollInterval, "The interval between checks for the Ingress controller Service external host")
+	cmd.Flags().BoolVarP(&options.CheckController, "check-controller", "", false, "Verifies that at least one pod of the Ingress controller is ready")
+	cmd.Flags().StringVarP(&options.RequirementsFile, "requirements-file", "", "", fmt.Sprintf("The requirements file to verify, e.g. of a remote environment checked out elsewhere. Defaults to the %s file found in --dir or its parents", config.RequirementsConfigFileName))
+	cmd.Flags().StringVarP(&options.OutputRequirements, "output-requirements", "", "", "If specified the updated requirements are written to this file rather than modifying the source requirements file")
+	cmd.Flags().BoolVarP(&options.DryRun, "dry-run", "", false, "Verifies the ingress without saving the updated requirements")
+	cmd.Flags().BoolVarP(&options.Strict, "strict", "", false, "Fails rather than warns if more than one recognized Ingress controller is installed or if the external IP of a NodePort Ingress controller is not the address of a node when discovering the domain")
+	cmd.Flags().BoolVarP(&options.RequireCustomDomain, "require-custom-domain", "", false, "Fails if the ingress domain is an automatic DNS domain such as nip.io rather than a custom domain")
+	cmd.Flags().BoolVarP(&options.ExportEnv, "export-env", "", false, "Writes the discovered ingress domain, container registry and Ingress controller as environment variables in KEY=VALUE form to the file specified via --export-env-file")
+	cmd.Flags().StringVarP(&options.ExportEnvFile, "export-env-file", "", "", fmt.Sprintf("The file the environment variables are written to with --export-env. Defaults to $%s", exportEnvFileEnvVar))
+	cmd.Flags().StringVarP(&options.RegistryService, "registry-service", "", "", fmt.Sprintf("The name of the Service of the in cluster container registry used to default the registry if none is configured. Defaults to the cluster.registryService in the %s file or %s", config.RequirementsConfigFileName, defaultRegistryService))
 	cmd.Flags().StringVarP(&options.LazyCreateFlag, "lazy-create", "", "", fmt.Sprintf("Specify true/false as to whether to lazily create missing resources. If not specified it is enabled if Terraform is not specified in the %s file", config.RequirementsConfigFileName))
 	return cmd
 }
@@ -111,7 +185,7 @@ func (o *StepVerifyIngressOptions) Run() error {
 			return fmt.Errorf("no default namespace found")
 		}
 	}
-	requirements, requirementsFileName, err := config.LoadRequirementsConfig(o.Dir)
+	requirements, requirementsFileName, err := o.loadRequirements()
 	if err != nil {
 		return errors.Wrapf(err, "failed to load Jenkins X requirements")
 	}
@@ -121,19 +195,46 @@ func (o *StepVerifyIngressOptions) Run() error {
 		return errors.Wrapf(err, "failed to see if lazy create flag is set %s", o.LazyCreateFlag)
 	}
 
-	if requirements.Cluster.Provider == "" {
+	o.Provider = o.resolveProvider(requirements)
+	if o.Provider == "" {
 		log.Logger().Warnf("No provider configured\n")
 	}
+	provider := o.Provider
+	// allow the Service to be specified as namespace/name, the --ingress-namespace flag takes precedence
+	if parts := strings.SplitN(o.IngressService, "/", 2); len(parts) == 2 {
+		if o.IngressNamespace == "" {
+			o.IngressNamespace = parts[0]
+		}
+		o.IngressService = parts[1]
+	}
+	defaultNamespace, defaultService := findDefaultIngressValues(provider)
+	if o.IngressNamespace == "" {
+		o.IngressNamespace = defaultNamespace
+	}
+	if o.IngressService == "" {
+		o.IngressService = defaultService
+	}
 
 	if requirements.Ingress.Domain == "" {
-		err = o.discoverIngressDomain(requirements, requirementsFileName)
+		err = o.discoverIngressDomain(requirements, requirementsFileName, ns)
 		if err != nil {
 			return errors.Wrapf(err, "failed to discover the Ingress domain")
 		}
 	}
 
+	if o.RequireCustomDomain && requirements.Ingress.IsAutoDNSDomain() {
+		return fmt.Errorf("the ingress domain %s is an automatic DNS domain but a custom domain is required, please configure the ingress.domain in the %s file", requirements.Ingress.Domain, config.RequirementsConfigFileName)
+	}
+
+	if o.CheckController {
+		err = o.verifyIngressControllerReady()
+		if err != nil {
+			return err
+		}
+	}
+
 	// if we're using GKE and folks have provided a domain, i.e. we're  not using the Jenkins X default nip.io
-	if requirements.Ingress.Domain != "" && !requirements.Ingress.IsAutoDNSDomain() && requirements.Cluster.Provider == cloud.GKE {
+	if requirements.Ingress.Domain != "" && !requirements.Ingress.IsAutoDNSDomain() && o.Provider == cloud.GKE {
 		// then it may be a good idea to enable external dns and TLS
 		if !requirements.Ingress.ExternalDNS {
 			log.Logger().Info("using a custom domain and GKE, you can enable external dns and TLS")
@@ -182,38 +283,410 @@ func (o *StepVerifyIngressOptions) Run() error {
 
 	// TLS uses cert-manager to ask LetsEncrypt for a signed certificate
 	if requirements.Ingress.TLS.Enabled {
-		if requirements.Cluster.Provider != cloud.GKE {
+		if o.Provider != cloud.GKE {
 			log.Logger().Warnf("Note that we have only tested TLS support on Google Container Engine with external-dns so far. This may not work!")
 		}
 
 		if requirements.Ingress.IsAutoDNSDomain() {
 			return fmt.Errorf("TLS is not supported with automated domains like %s, you will need to use a real domain you own", requirements.Ingress.Domain)
 		}
-		_, err = mail.ParseAddress(requirements.Ingress.TLS.Email)
+
+		kubeClient, err := o.KubeClient()
+		if err != nil {
+			return errors.Wrap(err, "creating kubernetes client")
+		}
+		// an explicitly configured secret takes precedence over looking for one
+		secretName := requirements.Ingress.TLS.ExistingSecret
+		if secretName == "" {
+			secretName, err = findExistingTLSSecret(kubeClient, requirements.Ingress.Domain, time.Now(), ns, o.IngressNamespace)
+			if err != nil {
+				return errors.Wrapf(err, "looking for an existing TLS secret for domain %s", requirements.Ingress.Domain)
+			}
+			requirements.Ingress.TLS.ExistingSecret = secretName
+		}
+		if secretName != "" {
+			log.Logger().Infof("found existing TLS secret %s for domain %s so cert-manager will not issue a new certificate", info(secretName), info(requirements.Ingress.Domain))
+		} else {
+			_, err = mail.ParseAddress(requirements.Ingress.TLS.Email)
+			if err != nil {
+				return errors.Wrap(err, "You must provide a valid email address to enable TLS so you can receive notifications from LetsEncrypt about your certificates")
+			}
+			err = verifyDNSProvider(kubeClient, &requirements.Ingress.TLS)
+			if err != nil {
+				return err
+			}
+			issuer, warning := acmeIssuer(requirements.Ingress.TLS, requirements.Ingress.Domain)
+			log.Logger().Infof("cert-manager will request the certificate for domain %s using the issuer %s", info(requirements.Ingress.Domain), info(issuer))
+			if warning != "" {
+				log.Logger().Warn(warning)
+			}
+		}
+	}
+
+	_, err = o.saveRequirements(requirements, requirementsFileName)
+	if err != nil {
+		return err
+	}
+
+	if o.ExportEnv {
+		return o.exportEnv(requirements)
+	}
+	return nil
+}
+
+// exportEnv writes the discovered ingress configuration in KEY=VALUE form to the export file so that later pipeline
+// steps do not need to parse the requirements
+func (o *StepVerifyIngressOptions) exportEnv(requirements *config.RequirementsConfig) error {
+	fileName := o.ExportEnvFile
+	if fileName == "" {
+		fileName = os.Getenv(exportEnvFileEnvVar)
+	}
+	if fileName == "" {
+		return util.MissingOption("export-env-file")
+	}
+	values := []struct {
+		name  string
+		value string
+	}{
+		{"INGRESS_DOMAIN", requirements.Ingress.Domain},
+		{"CONTAINER_REGISTRY", requirements.Cluster.Registry},
+		{"INGRESS_NAMESPACE", o.IngressNamespace},
+		{"INGRESS_SERVICE", o.IngressService},
+	}
+	var buffer strings.Builder
+	for _, v := range values {
+		buffer.WriteString(fmt.Sprintf("%s=%s\n", v.name, v.value))
+	}
+	err := ioutil.WriteFile(fileName, []byte(buffer.String()), util.DefaultWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write the environment variables to file: %s", fileName)
+	}
+	log.Logger().Infof("exported the ingress environment variables to %s", util.ColorInfo(fileName))
+	return nil
+}
+
+// resolveProvider returns the provider of the cluster, the --provider flag takes precedence over the provider in the
+// requirements. Aliases such as 'google' are resolved to the provider
+func (o *StepVerifyIngressOptions) resolveProvider(requirements *config.RequirementsConfig) string {
+	provider, source := o.Provider, "--provider flag"
+	if provider == "" {
+		provider, source = requirements.Cluster.Provider, fmt.Sprintf("%s file", config.RequirementsConfigFileName)
+	}
+	if provider == "" {
+		return ""
+	}
+	provider = cloud.ResolveProviderAlias(provider)
+	log.Logger().Infof("using the provider %s from the %s", util.ColorInfo(provider), source)
+	return provider
+}
+
+// loadRequirements loads the requirements from the requirements file if specified, otherwise by searching the
+// directory and its parents, returning the file name they were loaded from
+func (o *StepVerifyIngressOptions) loadRequirements() (*config.RequirementsConfig, string, error) {
+	if o.RequirementsFile == "" {
+		return config.LoadRequirementsConfig(o.Dir)
+	}
+	requirements, err := config.LoadRequirementsConfigFile(o.RequirementsFile)
+	return requirements, o.RequirementsFile, err
+}
+
+// nonPublicDomainSuffixes the suffixes of domains reserved for private networks or testing, which LetsEncrypt does
+// not issue certificates for
+var nonPublicDomainSuffixes = []string{
+	".corp",
+	".example",
+	".home",
+	".internal",
+	".invalid",
+	".lan",
+	".local",
+	".localhost",
+	".test",
+}
+
+// acmeIssuer returns the cert-manager issuer of the LetsEncrypt server selected via the production flag of the TLS
+// configuration, along with a warning if the production server is selected for a domain which is not public
+func acmeIssuer(tls config.TLSConfig, domain string) (string, string) {
+	if !tls.Production {
+		return pki.CertManagerIssuerStaging, ""
+	}
+	if !isPublicDomain(domain) {
+		return pki.CertManagerIssuerProd, fmt.Sprintf("the LetsEncrypt production server is selected but %s is not a public domain so no certificate can be issued, consider using the staging server by setting ingress.tls.production to false", domain)
+	}
+	return pki.CertManagerIssuerProd, ""
+}
+
+// isPublicDomain returns false for single label domains and domains reserved for private networks or testing
+func isPublicDomain(domain string) bool {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if !strings.Contains(domain, ".") {
+		return false
+	}
+	for _, suffix := range nonPublicDomainSuffixes {
+		if strings.HasSuffix(domain, suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// saveRequirements saves the requirements to the output requirements file if specified, otherwise to the source
+// requirements file, returning the file name written to. Nothing is written in dry run mode
+func (o *StepVerifyIngressOptions) saveRequirements(requirements *config.RequirementsConfig, requirementsFileName string) (string, error) {
+	fileName := requirementsFileName
+	if o.OutputRequirements != "" {
+		fileName = o.OutputRequirements
+	}
+	if o.DryRun {
+		log.Logger().Infof("dry run so not saving the requirements to %s", util.ColorInfo(fileName))
+		return fileName, nil
+	}
+	err := requirements.SaveConfig(fileName)
+	if err != nil {
+		return fileName, errors.Wrapf(err, "failed to save changes to file: %s", fileName)
+	}
+	return fileName, nil
+}
+
+// ingressController the Service of a recognized Ingress controller
+type ingressController struct {
+	kind      string
+	namespace string
+	service   string
+}
+
+// knownIngressControllers the Services of the recognized Ingress controllers in their default locations
+var knownIngressControllers = []ingressController{
+	{"nginx", opts.DefaultIngressNamesapce, opts.DefaultIngressServiceName},
+	{"nginx", "ingress-nginx", "ingress-nginx-controller"},
+	{"traefik", "kube-system", "traefik"},
+	{"istio", "istio-system", "istio-ingressgateway"},
+	{"openshift", "openshift-ingress", "router-default"},
+}
+
+// findIngressControllers returns a description of each recognized Ingress controller Service in the cluster
+func findIngressControllers(client kubernetes.Interface) ([]string, error) {
+	answer := []string{}
+	for _, c := range knownIngressControllers {
+		_, err := client.CoreV1().Services(c.namespace).Get(c.service, metav1.GetOptions{})
 		if err != nil {
-			return errors.Wrap(err, "You must provide a valid email address to enable TLS so you can receive notifications from LetsEncrypt about your certificates")
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return answer, errors.Wrapf(err, "getting the Service %s in namespace %s", c.service, c.namespace)
 		}
+		answer = append(answer, fmt.Sprintf("%s (%s/%s)", c.kind, c.namespace, c.service))
 	}
+	return answer, nil
+}
 
-	return requirements.SaveConfig(requirementsFileName)
+// verifySingleIngressController warns, or fails in strict mode, if more than one recognized Ingress controller is
+// installed as the domain may then be discovered from the wrong one
+func (o *StepVerifyIngressOptions) verifySingleIngressController(client kubernetes.Interface) error {
+	controllers, err := findIngressControllers(client)
+	if err != nil {
+		return err
+	}
+	if len(controllers) < 2 {
+		return nil
+	}
+	message := fmt.Sprintf("found multiple Ingress controllers: %s. The domain is discovered from %s/%s, use the --ingress-namespace and --ingress-service flags to choose the Ingress controller explicitly",
+		strings.Join(controllers, ", "), o.IngressNamespace, o.IngressService)
+	if o.Strict {
+		return errors.New(message)
+	}
+	log.Logger().Warn(message)
+	return nil
+}
+
+// findDefaultIngressValues returns the namespace and name of the Service of the Ingress controller used by default on
+// the provider, falling back to the nginx Ingress controller installed by Jenkins X
+func findDefaultIngressValues(provider string) (string, string) {
+	if ns, service, ok := cloud.DefaultIngressService(provider); ok {
+		return ns, service
+	}
+	return opts.DefaultIngressNamesapce, opts.DefaultIngressServiceName
 }
 
-func (o *StepVerifyIngressOptions) discoverIngressDomain(requirements *config.RequirementsConfig, requirementsFileName string) error {
+func (o *StepVerifyIngressOptions) discoverIngressDomain(requirements *config.RequirementsConfig, requirementsFileName string, ns string) error {
 	client, err := o.KubeClient()
-	var domain string
 	if err != nil {
 		return errors.Wrap(err, "getting the kubernetes client")
 	}
 
+	if requirements.Cluster.Registry == "" {
+		o.defaultRegistry(client, requirements, ns)
+	}
+
 	if requirements.Ingress.Domain != "" {
 		return nil
 	}
 
-	if o.Provider == "" {
-		o.Provider = requirements.Cluster.Provider
-		if o.Provider == "" {
-			log.Logger().Warnf("No provider configured\n")
+	if o.IngressHostnameOverride != "" {
+		domain, err := o.hostnameOverrideDomain()
+		if err != nil {
+			return err
 		}
+		return o.defaultDomain(requirements, requirementsFileName, domain)
+	}
+
+	err = o.verifySingleIngressController(client)
+	if err != nil {
+		return err
+	}
+
+	o.PrivateCluster = requirements.Cluster.IsPrivateIKS()
+	o.StrictExternalIP = o.Strict
+	if o.ExternalIP == "" {
+		o.ExternalIP = requirements.Ingress.ExternalIP
+	}
+	serviceType := requirements.Ingress.ServiceType
+	isNodePort := serviceType == string(corev1.ServiceTypeNodePort) || (serviceType == "" && cloud.UsesNodePortByDefault(o.Provider))
+	if o.ExternalIP == "" {
+		// a NodePort Service could be accessed via a different node on each run so lets record the node IP
+		nodeIP := o.findNodeExternalIP(client, isNodePort)
+		if nodeIP != "" {
+			log.Logger().Infof("using the external IP %s of a node to access the ingress controller", util.ColorInfo(nodeIP))
+			o.ExternalIP = nodeIP
+			requirements.Ingress.ExternalIP = nodeIP
+		}
+	}
+	domain, err := o.discoverIngressControllerDomain(client)
+	if err != nil || domain == "" {
+		// as a last resort lets use the domain of any existing Ingresses, e.g. when re-running boot
+		if ns == "" {
+			ns = requirements.Cluster.Namespace
+		}
+		ingressDomain, ingressErr := findDomainFromIngresses(client, ns)
+		if ingressErr != nil {
+			log.Logger().Warnf("failed to find the domain of the Ingresses in namespace %s: %s", ns, ingressErr)
+		}
+		if ingressDomain != "" {
+			log.Logger().Infof("using the domain %s of the existing Ingresses in namespace %s", util.ColorInfo(ingressDomain), util.ColorInfo(ns))
+			domain, err = ingressDomain, nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if domain == "" {
+		return fmt.Errorf("failed to discover domain for ingress service %s/%s", o.IngressNamespace, o.IngressService)
+	}
+	return o.defaultDomain(requirements, requirementsFileName, domain)
+}
+
+// defaultDomain sets the ingress domain in the requirements and saves them
+func (o *StepVerifyIngressOptions) defaultDomain(requirements *config.RequirementsConfig, requirementsFileName string, domain string) error {
+	requirements.Ingress.Domain = domain
+	fileName, err := o.saveRequirements(requirements, requirementsFileName)
+	if err != nil {
+		return err
+	}
+	log.Logger().Infof("defaulting the domain to %s and modified %s\n", util.ColorInfo(domain), util.ColorInfo(fileName))
+	return nil
+}
+
+// hostnameOverrideDomain returns the domain for the --ingress-hostname-override host. A custom host name is used as
+// the domain whereas an IP address, or the host name of a cloud load balancer resolved to an IP address, is used for
+// a nip.io domain
+func (o *StepVerifyIngressOptions) hostnameOverrideDomain() (string, error) {
+	host := o.IngressHostnameOverride
+	log.Logger().Infof("using the ingress host name override %s rather than discovering the Ingress controller", util.ColorInfo(host))
+	if net.ParseIP(host) != nil {
+		return host + ".nip.io", nil
+	}
+	if config.IsAutoDNSDomain(host) || !opts.IsCloudLoadBalancerHostname(host) {
+		return host, nil
+	}
+	ips, err := o.GetResolver().LookupIP(host)
+	if err != nil {
+		return "", errors.Wrapf(err, "resolving the ingress host name override %s", host)
+	}
+	for _, ip := range ips {
+		if !ip.IsLoopback() {
+			return ip.String() + ".nip.io", nil
+		}
+	}
+	return "", fmt.Errorf("the ingress host name override %s did not resolve to an IP address", host)
+}
+
+// defaultRegistry defaults the container registry to the address of the Service of the in cluster registry if it
+// exists. The --registry-service flag takes precedence over the Service name in the requirements
+func (o *StepVerifyIngressOptions) defaultRegistry(client kubernetes.Interface, requirements *config.RequirementsConfig, ns string) {
+	serviceName := o.RegistryService
+	if serviceName == "" {
+		serviceName = requirements.Cluster.RegistryService
+	}
+	if serviceName == "" {
+		serviceName = defaultRegistryService
+	}
+	if requirements.Cluster.Namespace != "" {
+		ns = requirements.Cluster.Namespace
+	}
+	svc, err := client.CoreV1().Services(ns).Get(serviceName, metav1.GetOptions{})
+	if err != nil {
+		log.Logger().Debugf("not defaulting the container registry as the Service %s/%s could not be found: %s", ns, serviceName, err)
+		return
+	}
+	if svc.Spec.ClusterIP == "" || len(svc.Spec.Ports) == 0 {
+		log.Logger().Debugf("not defaulting the container registry as the Service %s/%s has no cluster IP or port", ns, serviceName)
+		return
+	}
+	registry := fmt.Sprintf("%s:%d", svc.Spec.ClusterIP, svc.Spec.Ports[0].Port)
+	requirements.Cluster.Registry = registry
+	log.Logger().Infof("defaulting the container registry to %s from the Service %s", util.ColorInfo(registry), util.ColorInfo(serviceName))
+}
+
+// findNodeExternalIP returns the external IP of the node used to access a NodePort Ingress controller Service, or an
+// empty string if the Service is accessed some other way. If the ingress controller is known to be accessed via a
+// NodePort the external IP of a node is used whatever the type of the Service
+func (o *StepVerifyIngressOptions) findNodeExternalIP(client kubernetes.Interface, isNodePort bool) string {
+	if isNodePort {
+		nodeIP, err := opts.FindFirstExternalNodeIP(client)
+		if err != nil {
+			log.Logger().Debugf("failed to find the external IP of a node: %s", err)
+		}
+		if nodeIP != "" {
+			return nodeIP
+		}
+	}
+	candidates, err := o.GetDomainCandidates(client, o.Provider, o.IngressNamespace, o.IngressService, "")
+	if err != nil {
+		log.Logger().Debugf("failed to find the addresses of ingress service %s/%s: %s", o.IngressNamespace, o.IngressService, err)
+		return ""
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	switch candidates[0].Source {
+	case opts.DomainSourceNodeIP, opts.DomainSourcePodHostIP:
+		return candidates[0].Address
+	}
+	return ""
+}
+
+// discoverIngressControllerDomain discovers the domain from the address of the Ingress controller Service, waiting
+// for the Service to get an address if needed
+func (o *StepVerifyIngressOptions) discoverIngressControllerDomain(client kubernetes.Interface) (string, error) {
+	domain, err := o.GetDomain(client, "",
+		o.Provider,
+		o.IngressNamespace,
+		o.IngressService,
+		o.ExternalIP)
+	if err != nil && errors.Cause(err) != opts.ErrLoadBalancerPending {
+		return "", errors.Wrapf(err, "getting a domain for ingress service %s/%s", o.IngressNamespace, o.IngressService)
+	}
+	if domain != "" {
+		return domain, nil
+	}
+	hasHost, err := o.waitForIngressControllerHost(client, o.IngressNamespace, o.IngressService)
+	if err != nil {
+		return "", errors.Wrapf(err, "getting a domain for ingress service %s/%s", o.IngressNamespace, o.IngressService)
+	}
+	if !hasHost {
+		log.Logger().Warnf("could not find host for  ingress service %s/%s\n", o.IngressNamespace, o.IngressService)
+		return "", nil
 	}
 	domain, err = o.GetDomain(client, "",
 		o.Provider,
@@ -221,39 +694,157 @@ func (o *StepVerifyIngressOptions) discoverIngressDomain(requirements *config.Re
 		o.IngressService,
 		o.ExternalIP)
 	if err != nil {
-		return errors.Wrapf(err, "getting a domain for ingress service %s/%s", o.IngressNamespace, o.IngressService)
+		return "", errors.Wrapf(err, "getting a domain for ingress service %s/%s", o.IngressNamespace, o.IngressService)
 	}
-	if domain == "" {
-		hasHost, err := o.waitForIngressControllerHost(client, o.IngressNamespace, o.IngressService)
-		if err != nil {
-			return errors.Wrapf(err, "getting a domain for ingress service %s/%s", o.IngressNamespace, o.IngressService)
-		}
-		if hasHost {
-			domain, err = o.GetDomain(client, "",
-				o.Provider,
-				o.IngressNamespace,
-				o.IngressService,
-				o.ExternalIP)
-			if err != nil {
-				return errors.Wrapf(err, "getting a domain for ingress service %s/%s", o.IngressNamespace, o.IngressService)
+	return domain, nil
+}
+
+// findDomainFromIngresses infers the wildcard domain from the hosts of the Ingresses in the namespace by removing the
+// application sub domain from each host, returning the domain shared by most of the hosts
+func findDomainFromIngresses(client kubernetes.Interface, ns string) (string, error) {
+	if ns == "" {
+		return "", nil
+	}
+	ingresses, err := client.ExtensionsV1beta1().Ingresses(ns).List(metav1.ListOptions{})
+	if err != nil {
+		return "", errors.Wrapf(err, "listing the Ingresses in namespace %s", ns)
+	}
+	counts := map[string]int{}
+	for _, ing := range ingresses.Items {
+		for _, rule := range ing.Spec.Rules {
+			parts := strings.SplitN(rule.Host, ".", 2)
+			// the remaining domain needs at least two labels to be a wildcard domain
+			if len(parts) == 2 && strings.Contains(parts[1], ".") {
+				counts[parts[1]]++
 			}
-		} else {
-			log.Logger().Warnf("could not find host for  ingress service %s/%s\n", o.IngressNamespace, o.IngressService)
 		}
 	}
+	domains := make([]string, 0, len(counts))
+	for d := range counts {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+	domain := ""
+	for _, d := range domains {
+		if counts[d] > counts[domain] {
+			domain = d
+		}
+	}
+	return domain, nil
+}
 
-	if domain == "" {
-		return fmt.Errorf("failed to discover domain for ingress service %s/%s", o.IngressNamespace, o.IngressService)
+// verifyDNSProvider verifies that the DNS-01 provider, if any, is supported and that its credentials secret exists,
+// defaulting the name of the secret if not specified
+func verifyDNSProvider(kubeClient kubernetes.Interface, tls *config.TLSConfig) error {
+	provider := tls.DNSProvider
+	if provider == "" {
+		return nil
 	}
-	requirements.Ingress.Domain = domain
-	err = requirements.SaveConfig(requirementsFileName)
+	defaultSecretName, ok := dnsProviderCredentialsSecrets[provider]
+	if !ok {
+		return fmt.Errorf("unsupported DNS-01 provider %s, supported providers are: %s", provider, strings.Join(util.SortedMapKeys(dnsProviderCredentialsSecrets), ", "))
+	}
+	if tls.DNSCredentialsSecretName == "" {
+		tls.DNSCredentialsSecretName = defaultSecretName
+	}
+	secretName := tls.DNSCredentialsSecretName
+	ns := pki.CertManagerNamespace
+	_, err := kubeClient.CoreV1().Secrets(ns).Get(secretName, metav1.GetOptions{})
 	if err != nil {
-		return errors.Wrapf(err, "failed to save changes to file: %s", requirementsFileName)
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("the credentials secret %s for the DNS-01 provider %s was not found in namespace %s. Please create it so that cert-manager can solve the DNS-01 challenges, e.g. via: kubectl create secret generic %s -n %s --from-file=...",
+				secretName, provider, ns, secretName, ns)
+		}
+		return errors.Wrapf(err, "getting the credentials secret %s for the DNS-01 provider %s in namespace %s", secretName, provider, ns)
 	}
-	log.Logger().Infof("defaulting the domain to %s and modified %s\n", util.ColorInfo(domain), util.ColorInfo(requirementsFileName))
+	log.Logger().Infof("using the DNS-01 provider %s with the credentials secret %s", util.ColorInfo(provider), util.ColorInfo(secretName))
 	return nil
 }
 
+// findExistingTLSSecret returns the name of the first TLS secret in the given namespaces whose certificate covers the
+// domain, either directly or via a wildcard, and is valid at the given time, or an empty string if there is none
+func findExistingTLSSecret(kubeClient kubernetes.Interface, domain string, now time.Time, namespaces ...string) (string, error) {
+	checked := map[string]bool{}
+	for _, ns := range namespaces {
+		if ns == "" || checked[ns] {
+			continue
+		}
+		checked[ns] = true
+		secrets, err := kubeClient.CoreV1().Secrets(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return "", errors.Wrapf(err, "listing secrets in namespace %s", ns)
+		}
+		for _, secret := range secrets.Items {
+			if secret.Type != corev1.SecretTypeTLS {
+				continue
+			}
+			if certificateCoversDomain(secret.Data[corev1.TLSCertKey], domain, now) {
+				return secret.Name, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// certificateCoversDomain returns true if the PEM encoded certificate is valid at the given time for the domain and
+// all of its sub domains, e.g. a wildcard certificate for *.domain. An expired certificate is ignored so that
+// cert-manager issues a new one
+func certificateCoversDomain(data []byte, domain string, now time.Time) bool {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return false
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		log.Logger().Debugf("ignoring invalid TLS certificate: %s", err)
+		return false
+	}
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		log.Logger().Debugf("ignoring TLS certificate for %s which is only valid from %s until %s", strings.Join(cert.DNSNames, ", "),
+			cert.NotBefore.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339))
+		return false
+	}
+	wildcard := "*." + strings.ToLower(domain)
+	for _, name := range cert.DNSNames {
+		if strings.ToLower(name) == wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyIngressControllerReady verifies that at least one of the pods selected by the Ingress controller Service is ready
+func (o *StepVerifyIngressOptions) verifyIngressControllerReady() error {
+	kubeClient, err := o.KubeClient()
+	if err != nil {
+		return errors.Wrap(err, "creating kubernetes client")
+	}
+	ns := o.IngressNamespace
+	serviceName := o.IngressService
+	svc, err := kubeClient.CoreV1().Services(ns).Get(serviceName, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "finding the ingress controller service %s in namespace %s", serviceName, ns)
+	}
+	if len(svc.Spec.Selector) == 0 {
+		return fmt.Errorf("the ingress controller service %s in namespace %s has no pod selector", serviceName, ns)
+	}
+	selector := labels.SelectorFromSet(svc.Spec.Selector).String()
+	pods, err := kubeClient.CoreV1().Pods(ns).List(metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "listing the ingress controller pods in namespace %s with selector %s", ns, selector)
+	}
+	for i := range pods.Items {
+		if kube.IsPodReady(&pods.Items[i]) {
+			log.Logger().Infof("the ingress controller pod %s in namespace %s is ready", util.ColorInfo(pods.Items[i].Name), util.ColorInfo(ns))
+			return nil
+		}
+	}
+	return fmt.Errorf("none of the %d ingress controller pods in namespace %s matching %s are ready. Please check the ingress controller is running, e.g. via: kubectl get pods -n %s -l %s",
+		len(pods.Items), ns, selector, ns, selector)
+}
+
 func (o *StepVerifyIngressOptions) waitForIngressControllerHost(kubeClient kubernetes.Interface, ns, serviceName string) (bool, error) {
 	loggedWait := false
 	serviceInterface := kubeClient.CoreV1().Services(ns)
@@ -266,6 +857,15 @@ func (o *StepVerifyIngressOptions) waitForIngressControllerHost(kubeClient kuber
 		return false, err
 	}
 
+	timeout := o.IngressWaitTimeout
+	if timeout <= 0 {
+		timeout = defaultIngressWaitTimeout
+	}
+	pollInterval := o.IngressPollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultIngressPollInterval
+	}
+
 	fn := func() (bool, error) {
 		svc, err := serviceInterface.Get(serviceName, metav1.GetOptions{})
 		if err != nil {
@@ -281,11 +881,11 @@ func (o *StepVerifyIngressOptions) waitForIngressControllerHost(kubeClient kuber
 
 		if !loggedWait {
 			loggedWait = true
-			log.Logger().Infof("waiting for external Host on the ingress service %s in namespace %s ...", serviceName, ns)
+			log.Logger().Infof("waiting up to %s for external Host on the ingress service %s in namespace %s ...", timeout.String(), serviceName, ns)
 		}
 		return false, nil
 	}
-	err = o.RetryUntilTrueOrTimeout(time.Minute*5, time.Second*3, fn)
+	err = o.RetryUntilTrueOrTimeout(timeout, pollInterval, fn)
 	if err != nil {
 		return false, err
 	}
@@ -3,21 +3,30 @@
 package verify_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
 	"io/ioutil"
+	"math/big"
 	"os"
 	"path"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/jenkins-x/jx/pkg/cmd/opts/step"
 	"github.com/jenkins-x/jx/pkg/cmd/step/verify"
 	resources_test "github.com/jenkins-x/jx/pkg/kube/resources/mocks"
 
+	"github.com/jenkins-x/jx/pkg/cloud"
 	"github.com/jenkins-x/jx/pkg/config"
 
 	"github.com/jenkins-x/jx/pkg/cmd/testhelpers"
 	"github.com/jenkins-x/jx/pkg/gits"
 	"github.com/jenkins-x/jx/pkg/helm"
+	"github.com/jenkins-x/jx/pkg/log"
 	"github.com/jenkins-x/jx/pkg/util"
 	"k8s.io/apimachinery/pkg/runtime"
 
@@ -25,6 +34,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -88,6 +98,499 @@ func TestVerifyIngress(t *testing.T) {
 	require.NoError(t, err, "failed to run step")
 }
 
+func TestVerifyIngressDiscoversDomain(t *testing.T) {
+	tests := []struct {
+		name               string
+		provider           string
+		objects            []runtime.Object
+		configure          func(o *verify.StepVerifyIngressOptions)
+		expectedDomain     string
+		expectedExternalIP string
+		expectedRegistry   string
+	}{
+		{
+			name:           "load balancer IP",
+			objects:        []runtime.Object{defaultLoadBalancerService("1.2.3.4")},
+			expectedDomain: "1.2.3.4.nip.io",
+		},
+		{
+			name: "node external IP of a NodePort Service",
+			objects: []runtime.Object{
+				nodePortService(opts.DefaultIngressNamesapce, opts.DefaultIngressServiceName),
+				node("node-1", "10.0.0.1", "35.1.2.3"),
+			},
+			expectedDomain:     "35.1.2.3.nip.io",
+			expectedExternalIP: "35.1.2.3",
+		},
+		{
+			// the Service is mistakenly a LoadBalancer which will never get an address on premise
+			name:     "node external IP of a provider using a NodePort by default",
+			provider: cloud.KUBERNETES,
+			objects: []runtime.Object{
+				loadBalancerService(opts.DefaultIngressNamesapce, opts.DefaultIngressServiceName, ""),
+				node("node-1", "", "35.1.2.3"),
+			},
+			expectedDomain:     "35.1.2.3.nip.io",
+			expectedExternalIP: "35.1.2.3",
+		},
+		{
+			name:     "node external IP of a Rancher cluster",
+			provider: cloud.RKE,
+			objects: []runtime.Object{
+				loadBalancerService(opts.DefaultIngressNamesapce, opts.DefaultIngressServiceName, ""),
+				node("node-1", "", "35.1.2.4"),
+			},
+			expectedDomain:     "35.1.2.4.nip.io",
+			expectedExternalIP: "35.1.2.4",
+		},
+		{
+			name:     "registry from the registry Service",
+			provider: cloud.GKE,
+			objects: []runtime.Object{
+				defaultLoadBalancerService("1.2.3.4"),
+				&corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "harbor-registry",
+						Namespace: "jx",
+					},
+					Spec: corev1.ServiceSpec{
+						ClusterIP: "10.0.0.10",
+						Ports: []corev1.ServicePort{
+							{Port: 5000},
+						},
+					},
+				},
+			},
+			configure: func(o *verify.StepVerifyIngressOptions) {
+				o.RegistryService = "harbor-registry"
+			},
+			expectedDomain:   "1.2.3.4.nip.io",
+			expectedRegistry: "10.0.0.10:5000",
+		},
+		{
+			// there is no Ingress controller Service so the domain can only come from the override
+			name: "custom host name override",
+			configure: func(o *verify.StepVerifyIngressOptions) {
+				o.IngressHostnameOverride = "proxy.example.com"
+			},
+			expectedDomain: "proxy.example.com",
+		},
+		{
+			name: "IP address host name override",
+			configure: func(o *verify.StepVerifyIngressOptions) {
+				o.IngressHostnameOverride = "5.6.7.8"
+			},
+			expectedDomain: "5.6.7.8.nip.io",
+		},
+		{
+			name: "namespace/name ingress service",
+			objects: []runtime.Object{
+				loadBalancerService("istio-system", "istio-ingressgateway", "1.2.3.4"),
+			},
+			configure: func(o *verify.StepVerifyIngressOptions) {
+				o.IngressNamespace = ""
+				o.IngressService = "istio-system/istio-ingressgateway"
+			},
+			expectedDomain: "1.2.3.4.nip.io",
+		},
+		{
+			name: "namespace flag takes precedence over namespace/name",
+			objects: []runtime.Object{
+				loadBalancerService("custom-istio", "istio-ingressgateway", "1.2.3.4"),
+			},
+			configure: func(o *verify.StepVerifyIngressOptions) {
+				o.IngressNamespace = "custom-istio"
+				o.IngressService = "istio-system/istio-ingressgateway"
+			},
+			expectedDomain: "1.2.3.4.nip.io",
+		},
+		{
+			// there is no Ingress controller Service to discover the domain from
+			name: "domain of existing Ingresses",
+			objects: []runtime.Object{
+				ingress("hook", "hook-jx.apps.example.com"),
+				ingress("chartmuseum", "chartmuseum-jx.apps.example.com"),
+				ingress("other", "other.example.org"),
+			},
+			configure: func(o *verify.StepVerifyIngressOptions) {
+				o.IngressNamespace = ""
+				o.IngressService = ""
+			},
+			expectedDomain: "apps.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requirements := getRequirements()
+			requirements.Cluster.Provider = tt.provider
+			dir, fileName := requirementsDir(t, requirements)
+			defer os.RemoveAll(dir)
+
+			o := newVerifyIngressOptions(dir, tt.objects...)
+			if tt.configure != nil {
+				tt.configure(o)
+			}
+
+			err := o.Run()
+			require.NoError(t, err, "failed to run step")
+
+			requirements, err = config.LoadRequirementsConfigFile(fileName)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedDomain, requirements.Ingress.Domain)
+			assert.Equal(t, tt.expectedExternalIP, requirements.Ingress.ExternalIP)
+			assert.Equal(t, tt.expectedRegistry, requirements.Cluster.Registry)
+		})
+	}
+}
+
+func TestVerifyIngressTimesOutWaitingForHost(t *testing.T) {
+	dir, _ := requirementsDir(t, getRequirements())
+	defer os.RemoveAll(dir)
+
+	o := newVerifyIngressOptions(dir, loadBalancerService(opts.DefaultIngressNamesapce, opts.DefaultIngressServiceName, ""))
+	o.IngressWaitTimeout = time.Millisecond * 50
+	o.IngressPollInterval = time.Millisecond * 10
+
+	err := o.Run()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Timed out after 50ms")
+}
+
+func TestVerifyIngressCheckControllerWithNoReadyPods(t *testing.T) {
+	requirements := getRequirements()
+	requirements.Ingress.Domain = "1.2.3.4.nip.io"
+	dir, _ := requirementsDir(t, requirements)
+	defer os.RemoveAll(dir)
+
+	controllerLabels := map[string]string{
+		"app":       "nginx-ingress",
+		"component": "controller",
+	}
+	service := loadBalancerService(opts.DefaultIngressNamesapce, opts.DefaultIngressServiceName, "")
+	service.Spec.Selector = controllerLabels
+	o := newVerifyIngressOptions(dir,
+		service,
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "jxing-nginx-ingress-controller-abc",
+				Namespace: opts.DefaultIngressNamesapce,
+				Labels:    controllerLabels,
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				Conditions: []corev1.PodCondition{
+					{
+						Type:   corev1.PodReady,
+						Status: corev1.ConditionFalse,
+					},
+				},
+			},
+		},
+	)
+	o.CheckController = true
+
+	err := o.Run()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "none of the 1 ingress controller pods")
+}
+
+func TestVerifyIngressTLSWithExistingWildcardSecret(t *testing.T) {
+	tests := []struct {
+		name           string
+		existingSecret string
+		objects        []runtime.Object
+		expectedSecret string
+	}{
+		{
+			name: "valid wildcard secret",
+			objects: []runtime.Object{
+				tlsSecret("tls-other", createTestCertificate(t, "*.another.com")),
+				tlsSecret("tls-example-com", createTestCertificate(t, "*.example.com")),
+			},
+			expectedSecret: "tls-example-com",
+		},
+		{
+			// cert-manager should issue a new certificate rather than the expired one being served
+			name: "expired wildcard secret",
+			objects: []runtime.Object{
+				tlsSecret("tls-example-com", createTestCertificateValidUntil(t, time.Now().Add(-time.Hour), "*.example.com")),
+			},
+			expectedSecret: "",
+		},
+		{
+			name:           "configured secret is kept",
+			existingSecret: "my-tls-secret",
+			expectedSecret: "my-tls-secret",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requirements := getRequirements()
+			requirements.Ingress.Domain = "example.com"
+			requirements.Ingress.TLS.Enabled = true
+			requirements.Ingress.TLS.Email = "jenkins-x@example.com"
+			requirements.Ingress.TLS.ExistingSecret = tt.existingSecret
+			dir, fileName := requirementsDir(t, requirements)
+			defer os.RemoveAll(dir)
+
+			o := newVerifyIngressOptions(dir, tt.objects...)
+
+			err := o.Run()
+			require.NoError(t, err, "failed to run step")
+
+			requirements, err = config.LoadRequirementsConfigFile(fileName)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedSecret, requirements.Ingress.TLS.ExistingSecret)
+		})
+	}
+}
+
+func TestVerifyIngressTLSWithDNSProvider(t *testing.T) {
+	for _, hasCredentials := range []bool{true, false} {
+		requirements := getRequirements()
+		requirements.Ingress.Domain = "internal.example.com"
+		requirements.Ingress.TLS.Enabled = true
+		requirements.Ingress.TLS.Email = "jenkins-x@example.com"
+		requirements.Ingress.TLS.DNSProvider = "route53"
+		dir, fileName := requirementsDir(t, requirements)
+		defer os.RemoveAll(dir)
+
+		objects := []runtime.Object{}
+		if hasCredentials {
+			objects = append(objects, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "route53-credentials",
+					Namespace: "cert-manager",
+				},
+				Data: map[string][]byte{
+					"secret-access-key": []byte("secret"),
+				},
+			})
+		}
+		o := newVerifyIngressOptions(dir, objects...)
+
+		err := o.Run()
+		if !hasCredentials {
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "the credentials secret route53-credentials for the DNS-01 provider route53 was not found")
+			continue
+		}
+		require.NoError(t, err, "failed to run step")
+
+		requirements, err = config.LoadRequirementsConfigFile(fileName)
+		require.NoError(t, err)
+		assert.Equal(t, "route53-credentials", requirements.Ingress.TLS.DNSCredentialsSecretName)
+	}
+}
+
+func TestVerifyIngressOutputRequirements(t *testing.T) {
+	for _, dryRun := range []bool{false, true} {
+		dir, sourceFileName := requirementsDir(t, getRequirements())
+		defer os.RemoveAll(dir)
+		source, err := ioutil.ReadFile(sourceFileName)
+		require.NoError(t, err)
+
+		outputFileName := filepath.Join(dir, "discovered-requirements.yml")
+		o := newVerifyIngressOptions(dir, defaultLoadBalancerService("1.2.3.4"))
+		o.OutputRequirements = outputFileName
+		o.DryRun = dryRun
+
+		err = o.Run()
+		require.NoError(t, err, "failed to run step with dry run %t", dryRun)
+
+		actual, err := ioutil.ReadFile(sourceFileName)
+		require.NoError(t, err)
+		assert.Equal(t, string(source), string(actual), "the source requirements should be unchanged with dry run %t", dryRun)
+
+		if dryRun {
+			_, err = os.Stat(outputFileName)
+			assert.True(t, os.IsNotExist(err), "the output requirements should not be written with dry run")
+			continue
+		}
+		requirements, err := config.LoadRequirementsConfigFile(outputFileName)
+		require.NoError(t, err)
+		assert.Equal(t, "1.2.3.4.nip.io", requirements.Ingress.Domain)
+	}
+}
+
+func TestVerifyIngressExportEnv(t *testing.T) {
+	requirements := getRequirements()
+	requirements.Cluster.Registry = "gcr.io"
+	dir, _ := requirementsDir(t, requirements)
+	defer os.RemoveAll(dir)
+
+	envFileName := filepath.Join(dir, "ingress.env")
+	o := newVerifyIngressOptions(dir, defaultLoadBalancerService("1.2.3.4"))
+	o.ExportEnv = true
+	o.ExportEnvFile = envFileName
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run step")
+
+	actual, err := ioutil.ReadFile(envFileName)
+	require.NoError(t, err)
+	expected := "INGRESS_DOMAIN=1.2.3.4.nip.io\n" +
+		"CONTAINER_REGISTRY=gcr.io\n" +
+		"INGRESS_NAMESPACE=" + opts.DefaultIngressNamesapce + "\n" +
+		"INGRESS_SERVICE=" + opts.DefaultIngressServiceName + "\n"
+	assert.Equal(t, expected, string(actual))
+}
+
+func TestVerifyIngressRequirementsFile(t *testing.T) {
+	// the requirements in the directory should be ignored in favour of the requirements file
+	dir, dirFileName := requirementsDir(t, getRequirements())
+	defer os.RemoveAll(dir)
+
+	requirementsFileName := filepath.Join(dir, "environment-staging", "staging-requirements.yml")
+	err := os.MkdirAll(filepath.Dir(requirementsFileName), util.DefaultWritePermissions)
+	require.NoError(t, err)
+	err = getRequirements().SaveConfig(requirementsFileName)
+	require.NoError(t, err)
+
+	o := newVerifyIngressOptions(dir, defaultLoadBalancerService("1.2.3.4"))
+	o.RequirementsFile = requirementsFileName
+
+	err = o.Run()
+	require.NoError(t, err, "failed to run step")
+
+	requirements, err := config.LoadRequirementsConfigFile(requirementsFileName)
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.3.4.nip.io", requirements.Ingress.Domain)
+
+	requirements, err = config.LoadRequirementsConfigFile(dirFileName)
+	require.NoError(t, err)
+	assert.Equal(t, "", requirements.Ingress.Domain, "the requirements in the directory should be unchanged")
+}
+
+func TestVerifyIngressProviderPrecedence(t *testing.T) {
+	tests := []struct {
+		name                 string
+		flagProvider         string
+		requirementsProvider string
+		want                 string
+	}{
+		{"requirements used without flag", "", cloud.GKE, cloud.GKE},
+		{"requirements alias resolved", "", "google", cloud.GKE},
+		{"flag takes precedence", cloud.KUBERNETES, cloud.GKE, cloud.KUBERNETES},
+		{"no provider", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requirements := getRequirements()
+			requirements.Cluster.Provider = tt.requirementsProvider
+			dir, _ := requirementsDir(t, requirements)
+			defer os.RemoveAll(dir)
+
+			o := newVerifyIngressOptions(dir, defaultLoadBalancerService("1.2.3.4"))
+			o.Provider = tt.flagProvider
+			o.DryRun = true
+
+			err := o.Run()
+			require.NoError(t, err, "failed to run step")
+			assert.Equal(t, tt.want, o.Provider)
+		})
+	}
+}
+
+func TestVerifyIngressProviderDefaults(t *testing.T) {
+	tests := []struct {
+		provider  string
+		domain    string
+		namespace string
+		service   string
+	}{
+		{cloud.OPENSHIFT, "", "openshift-ingress", "router-default"},
+		// on AWS a custom domain is required in batch mode
+		{cloud.EKS, "foobar.com", "kube-system", "aws-load-balancer-webhook-service"},
+	}
+
+	for _, test := range tests {
+		requirements := getRequirements()
+		requirements.Cluster.Provider = test.provider
+		requirements.Ingress.Domain = test.domain
+		dir, fileName := requirementsDir(t, requirements)
+		defer os.RemoveAll(dir)
+
+		o := newVerifyIngressOptions(dir, loadBalancerService(test.namespace, test.service, "1.2.3.4"))
+		o.IngressNamespace = ""
+		o.IngressService = ""
+
+		err := o.Run()
+		require.NoError(t, err, "failed to run step for provider %s", test.provider)
+
+		assert.Equal(t, test.namespace, o.IngressNamespace, "ingress namespace for provider %s", test.provider)
+		assert.Equal(t, test.service, o.IngressService, "ingress service for provider %s", test.provider)
+
+		if test.domain == "" {
+			requirements, err = config.LoadRequirementsConfigFile(fileName)
+			require.NoError(t, err)
+			assert.Equal(t, "1.2.3.4.nip.io", requirements.Ingress.Domain, "ingress domain for provider %s", test.provider)
+		}
+	}
+}
+
+func TestVerifyIngressWithMultipleIngressControllers(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		dir, _ := requirementsDir(t, getRequirements())
+		defer os.RemoveAll(dir)
+
+		o := newVerifyIngressOptions(dir,
+			defaultLoadBalancerService("1.2.3.4"),
+			loadBalancerService("istio-system", "istio-ingressgateway", "5.6.7.8"),
+		)
+		o.DryRun = true
+		o.Strict = strict
+
+		var err error
+		output := log.CaptureOutput(func() {
+			err = o.Run()
+		})
+		if strict {
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "istio (istio-system/istio-ingressgateway)")
+			continue
+		}
+		require.NoError(t, err)
+		assert.Contains(t, output, "found multiple Ingress controllers")
+		assert.Contains(t, output, "nginx (kube-system/jxing-nginx-ingress-controller)")
+		assert.Contains(t, output, "istio (istio-system/istio-ingressgateway)")
+	}
+}
+
+func TestVerifyIngressRequireCustomDomain(t *testing.T) {
+	t.Parallel()
+
+	for domain, valid := range map[string]bool{
+		"34.76.24.247.nip.io": false,
+		"foobar.com":          true,
+	} {
+		o := verify.StepVerifyIngressOptions{
+			StepOptions: step.StepOptions{
+				CommonOptions: &opts.CommonOptions{},
+			},
+			RequireCustomDomain: true,
+		}
+
+		dir, err := ioutil.TempDir("", "test-requirements-custom-domain-")
+		require.NoError(t, err, "should create a temporary config dir")
+		defer os.RemoveAll(dir)
+
+		o.Dir = dir
+		requirements := getRequirements()
+		requirements.Ingress.Domain = domain
+		requirements.Cluster.Provider = "aws"
+		err = requirements.SaveConfig(filepath.Join(dir, config.RequirementsConfigFileName))
+		require.NoError(t, err)
+
+		err = o.Run()
+		if valid {
+			assert.NoError(t, err, "custom domain %s should be accepted", domain)
+		} else {
+			assert.Error(t, err, "automatic DNS domain %s should be rejected", domain)
+		}
+	}
+}
+
 func TestExternalDNSDisabledDomainNotOwned(t *testing.T) {
 	t.Parallel()
 
@@ -170,6 +673,24 @@ func TestExternalDNSDisabledNotGKE(t *testing.T) {
 
 }
 
+func TestExternalDNSUsesProviderFlag(t *testing.T) {
+	t.Parallel()
+
+	requirements := getRequirements()
+	requirements.Ingress.Domain = "foobar.com"
+	requirements.Ingress.ExternalDNS = true
+	requirements.Cluster.Provider = "gke"
+	dir, _ := requirementsDir(t, requirements)
+	defer os.RemoveAll(dir)
+
+	// the --provider flag takes precedence so the GKE external-dns secret should not be validated
+	o := newVerifyIngressOptions(dir)
+	o.Provider = "aws"
+
+	err := o.Run()
+	assert.NoError(t, err, "failed to run step in dir %s", dir)
+}
+
 func getRequirements() *config.RequirementsConfig {
 	requirements := config.NewRequirementsConfig()
 	requirements.Cluster.ProjectID = "test-project"
@@ -179,6 +700,141 @@ func getRequirements() *config.RequirementsConfig {
 	return requirements
 }
 
+// requirementsDir saves the requirements into a new temporary directory, returning the directory and the name of the
+// requirements file
+func requirementsDir(t *testing.T, requirements *config.RequirementsConfig) (string, string) {
+	dir, err := ioutil.TempDir("", "test-step-verify-ingress-")
+	require.NoError(t, err)
+	fileName := filepath.Join(dir, config.RequirementsConfigFileName)
+	err = requirements.SaveConfig(fileName)
+	require.NoError(t, err)
+	return dir, fileName
+}
+
+// newVerifyIngressOptions creates the options to verify the requirements in the directory in batch mode using the
+// default Ingress controller Service, with fake clients containing the Kubernetes objects
+func newVerifyIngressOptions(dir string, objects ...runtime.Object) *verify.StepVerifyIngressOptions {
+	o := &verify.StepVerifyIngressOptions{
+		StepOptions: step.StepOptions{
+			CommonOptions: &opts.CommonOptions{
+				In:        os.Stdin,
+				Out:       os.Stdout,
+				Err:       os.Stderr,
+				BatchMode: true,
+			},
+		},
+		Dir:              dir,
+		Namespace:        "jx",
+		IngressNamespace: opts.DefaultIngressNamesapce,
+		IngressService:   opts.DefaultIngressServiceName,
+	}
+	testhelpers.ConfigureTestOptionsWithResources(o.CommonOptions,
+		objects,
+		nil,
+		gits.NewGitCLI(),
+		nil,
+		helm.NewHelmCLI("helm", helm.V2, "", true),
+		resources_test.NewMockInstaller(),
+	)
+	return o
+}
+
+// loadBalancerService creates a LoadBalancer Service with the IP address, or without an address yet if it is empty
+func loadBalancerService(namespace, name, ip string) *corev1.Service {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: corev1.ServiceSpec{
+			Type: corev1.ServiceTypeLoadBalancer,
+		},
+	}
+	if ip != "" {
+		svc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: ip}}
+	}
+	return svc
+}
+
+// defaultLoadBalancerService creates the default Ingress controller Service as a LoadBalancer with the IP address
+func defaultLoadBalancerService(ip string) *corev1.Service {
+	return loadBalancerService(opts.DefaultIngressNamesapce, opts.DefaultIngressServiceName, ip)
+}
+
+func nodePortService(namespace, name string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: corev1.ServiceSpec{
+			Type: corev1.ServiceTypeNodePort,
+		},
+	}
+}
+
+// node creates a Node with the internal and external IP addresses which are not empty
+func node(name, internalIP, externalIP string) *corev1.Node {
+	n := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+	}
+	if internalIP != "" {
+		n.Status.Addresses = append(n.Status.Addresses, corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: internalIP})
+	}
+	if externalIP != "" {
+		n.Status.Addresses = append(n.Status.Addresses, corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: externalIP})
+	}
+	return n
+}
+
+func ingress(name string, host string) *extensionsv1beta1.Ingress {
+	return &extensionsv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "jx",
+		},
+		Spec: extensionsv1beta1.IngressSpec{
+			Rules: []extensionsv1beta1.IngressRule{
+				{Host: host},
+			},
+		},
+	}
+}
+
+func tlsSecret(name string, cert []byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "jx",
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey: cert,
+		},
+	}
+}
+
+func createTestCertificate(t *testing.T, dnsNames ...string) []byte {
+	return createTestCertificateValidUntil(t, time.Now().Add(time.Hour), dnsNames...)
+}
+
+func createTestCertificateValidUntil(t *testing.T, notAfter time.Time, dnsNames ...string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     dnsNames,
+		NotBefore:    notAfter.Add(-2 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
 func AssertMapPathValueAsString(t *testing.T, values map[string]interface{}, path string, expected string) {
 	actual := util.GetMapValueAsStringViaPath(values, path)
 	assert.Equal(t, expected, actual, "invalid helm value for path %s", path)
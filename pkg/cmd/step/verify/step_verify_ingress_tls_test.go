@@ -0,0 +1,39 @@
+// +build unit
+
+package verify
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/kube/pki"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestACMEIssuer(t *testing.T) {
+	tests := []struct {
+		name        string
+		production  bool
+		domain      string
+		wantIssuer  string
+		wantWarning bool
+	}{
+		{"staging", false, "example.com", pki.CertManagerIssuerStaging, false},
+		{"staging with private domain", false, "jx.corp.internal", pki.CertManagerIssuerStaging, false},
+		{"production", true, "example.com", pki.CertManagerIssuerProd, false},
+		{"production with private domain", true, "jx.corp.internal", pki.CertManagerIssuerProd, true},
+		{"production with local domain", true, "jx.local.", pki.CertManagerIssuerProd, true},
+		{"production with single label domain", true, "jx", pki.CertManagerIssuerProd, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer, warning := acmeIssuer(config.TLSConfig{Enabled: true, Production: tt.production}, tt.domain)
+			assert.Equal(t, tt.wantIssuer, issuer)
+			if tt.wantWarning {
+				assert.Contains(t, warning, tt.domain)
+			} else {
+				assert.Empty(t, warning)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package verify
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/applications"
+	"github.com/jenkins-x/jx/pkg/cmd/helper"
+	"github.com/jenkins-x/jx/pkg/cmd/opts"
+	"github.com/jenkins-x/jx/pkg/cmd/opts/step"
+	"github.com/jenkins-x/jx/pkg/cmd/templates"
+	"github.com/jenkins-x/jx/pkg/gits"
+	"github.com/jenkins-x/jx/pkg/kube"
+	"github.com/jenkins-x/jx/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+const (
+	remoteEnvReachable   = "reachable"
+	remoteEnvUnreachable = "unreachable"
+)
+
+var (
+	stepVerifyRemoteEnvLong = templates.LongDesc(`
+		Verifies that the clusters of the remote environments can be reached by loading the requirements from the
+		git repository of each remote environment and listing the deployments in its namespace
+`)
+
+	stepVerifyRemoteEnvExample = templates.Examples(`
+		jx step verify remote-env
+
+		# fail if any of the remote environments cannot be reached
+		jx step verify remote-env --fail-on-unreachable
+`)
+)
+
+// StepVerifyRemoteEnvOptions contains the command line flags
+type StepVerifyRemoteEnvOptions struct {
+	step.StepOptions
+
+	FailOnUnreachable bool
+
+	// getRemoteDeployments fetches the deployments of a remote environment, defaults to applications.GetRemoteDeployments
+	getRemoteDeployments func(gits.Gitter, *v1.Environment) (map[string]appsv1.Deployment, error)
+}
+
+// remoteEnvStatus the result of verifying a remote environment
+type remoteEnvStatus struct {
+	Name   string
+	Status string
+	Error  error
+}
+
+// NewCmdStepVerifyRemoteEnv creates the `jx step verify remote-env` command
+func NewCmdStepVerifyRemoteEnv(commonOpts *opts.CommonOptions) *cobra.Command {
+	options := &StepVerifyRemoteEnvOptions{
+		StepOptions: step.StepOptions{
+			CommonOptions: commonOpts,
+		},
+	}
+	cmd := &cobra.Command{
+		Use:     "remote-env",
+		Short:   "Verifies that the clusters of the remote environments can be reached",
+		Long:    stepVerifyRemoteEnvLong,
+		Example: stepVerifyRemoteEnvExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			options.Cmd = cmd
+			options.Args = args
+			err := options.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().BoolVarP(&options.FailOnUnreachable, "fail-on-unreachable", "", false, "Fails the step if any of the remote environments cannot be reached")
+	return cmd
+}
+
+// Run implements this command
+func (o *StepVerifyRemoteEnvOptions) Run() error {
+	statuses, err := o.verifyRemoteEnvironments()
+	if err != nil {
+		return err
+	}
+	if len(statuses) == 0 {
+		log.Logger().Infof("No remote environments found")
+		return nil
+	}
+
+	table := o.CreateTable()
+	table.AddRow("ENVIRONMENT", "STATUS", "ERROR")
+	unreachable := []string{}
+	for _, s := range statuses {
+		message := ""
+		if s.Error != nil {
+			message = s.Error.Error()
+			unreachable = append(unreachable, s.Name)
+		}
+		table.AddRow(s.Name, s.Status, message)
+	}
+	table.Render()
+
+	if o.FailOnUnreachable && len(unreachable) > 0 {
+		return fmt.Errorf("the remote environments %s are unreachable", strings.Join(unreachable, ", "))
+	}
+	return nil
+}
+
+// verifyRemoteEnvironments attempts to fetch the deployments of each remote environment in promotion order
+func (o *StepVerifyRemoteEnvOptions) verifyRemoteEnvironments() ([]remoteEnvStatus, error) {
+	jxClient, ns, err := o.JXClientAndDevNamespace()
+	if err != nil {
+		return nil, errors.Wrap(err, "creating the jx client")
+	}
+	envMap, names, err := kube.GetOrderedEnvironments(jxClient, ns)
+	if err != nil {
+		return nil, errors.Wrapf(err, "loading the environments in namespace %s", ns)
+	}
+
+	getRemoteDeployments := o.getRemoteDeployments
+	if getRemoteDeployments == nil {
+		getRemoteDeployments = applications.GetRemoteDeployments
+	}
+	statuses := []remoteEnvStatus{}
+	for _, name := range names {
+		env := envMap[name]
+		if !env.Spec.RemoteCluster {
+			continue
+		}
+		status := remoteEnvStatus{
+			Name:   name,
+			Status: remoteEnvReachable,
+		}
+		_, err := getRemoteDeployments(o.Git(), env)
+		if err != nil {
+			log.Logger().Debugf("remote environment %s is unreachable: %s", name, err)
+			status.Status = remoteEnvUnreachable
+			status.Error = err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
@@ -0,0 +1,80 @@
+// +build unit
+
+package verify
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	v1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/cmd/opts"
+	"github.com/jenkins-x/jx/pkg/cmd/opts/step"
+	"github.com/jenkins-x/jx/pkg/cmd/testhelpers"
+	"github.com/jenkins-x/jx/pkg/gits"
+	"github.com/jenkins-x/jx/pkg/helm"
+	"github.com/jenkins-x/jx/pkg/kube"
+	resources_test "github.com/jenkins-x/jx/pkg/kube/resources/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestStepVerifyRemoteEnv(t *testing.T) {
+	remoteEnv := func(name string, order int32) *v1.Environment {
+		env := kube.NewPermanentEnvironmentWithGit(name, fmt.Sprintf("https://github.com/my-org/environment-%s.git", name))
+		env.Spec.Namespace = "jx-" + name
+		env.Spec.Order = order
+		env.Spec.RemoteCluster = true
+		return env
+	}
+	jxObjects := []runtime.Object{
+		remoteEnv("staging", 100),
+		remoteEnv("production", 200),
+	}
+
+	for _, failOnUnreachable := range []bool{false, true} {
+		o := &StepVerifyRemoteEnvOptions{
+			StepOptions: step.StepOptions{
+				CommonOptions: &opts.CommonOptions{
+					In:  os.Stdin,
+					Out: os.Stdout,
+					Err: os.Stderr,
+				},
+			},
+			FailOnUnreachable: failOnUnreachable,
+			getRemoteDeployments: func(gitter gits.Gitter, env *v1.Environment) (map[string]appsv1.Deployment, error) {
+				if env.Name == "production" {
+					return nil, fmt.Errorf("connecting to the cluster of environment %s: timeout", env.Name)
+				}
+				return map[string]appsv1.Deployment{}, nil
+			},
+		}
+		testhelpers.ConfigureTestOptionsWithResources(o.CommonOptions,
+			nil,
+			jxObjects,
+			gits.NewGitFake(),
+			nil,
+			helm.NewHelmCLI("helm", helm.V2, "", true),
+			resources_test.NewMockInstaller(),
+		)
+
+		statuses, err := o.verifyRemoteEnvironments()
+		require.NoError(t, err)
+		require.Len(t, statuses, 2)
+		assert.Equal(t, "staging", statuses[0].Name)
+		assert.Equal(t, remoteEnvReachable, statuses[0].Status)
+		assert.NoError(t, statuses[0].Error)
+		assert.Equal(t, "production", statuses[1].Name)
+		assert.Equal(t, remoteEnvUnreachable, statuses[1].Status)
+		assert.Error(t, statuses[1].Error)
+
+		err = o.Run()
+		if failOnUnreachable {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+}
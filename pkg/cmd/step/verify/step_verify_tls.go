@@ -0,0 +1,143 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/jenkins-x/jx/pkg/cmd/helper"
+	"github.com/jenkins-x/jx/pkg/cmd/opts"
+	"github.com/jenkins-x/jx/pkg/cmd/opts/step"
+	"github.com/jenkins-x/jx/pkg/cmd/templates"
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/log"
+	"github.com/jenkins-x/jx/pkg/util"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	stepVerifyTLSLong = templates.LongDesc(`
+		Verifies that the cluster serves a valid TLS certificate for the ingress domain in the requirements by connecting
+		to https://<domain> and checking that the certificate has not expired, is not self-signed and matches the domain
+`)
+
+	stepVerifyTLSExample = templates.Examples(`
+		jx step verify tls
+
+		# verify the certificate of a staging LetsEncrypt issuer or of a private CA
+		jx step verify tls --allow-self-signed
+`)
+)
+
+// StepVerifyTLSOptions contains the command line flags
+type StepVerifyTLSOptions struct {
+	step.StepOptions
+
+	Dir             string
+	Domain          string
+	Address         string
+	AllowSelfSigned bool
+	Timeout         time.Duration
+}
+
+// NewCmdStepVerifyTLS creates the `jx step verify tls` command
+func NewCmdStepVerifyTLS(commonOpts *opts.CommonOptions) *cobra.Command {
+	options := &StepVerifyTLSOptions{
+		StepOptions: step.StepOptions{
+			CommonOptions: commonOpts,
+		},
+	}
+	cmd := &cobra.Command{
+		Use:     "tls",
+		Short:   "Verifies that the cluster serves a valid TLS certificate for the ingress domain",
+		Long:    stepVerifyTLSLong,
+		Example: stepVerifyTLSExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			options.Cmd = cmd
+			options.Args = args
+			err := options.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&options.Dir, "dir", "d", ".", "the directory to look for the requirements file")
+	cmd.Flags().StringVarP(&options.Domain, "domain", "", "", "the domain to verify, defaults to the ingress domain in the requirements")
+	cmd.Flags().StringVarP(&options.Address, "address", "", "", "the host and port to connect to, defaults to port 443 of the domain")
+	cmd.Flags().BoolVarP(&options.AllowSelfSigned, "allow-self-signed", "", false, "allows a self-signed certificate")
+	cmd.Flags().DurationVarP(&options.Timeout, "timeout", "", 30*time.Second, "the timeout to connect to the domain")
+	return cmd
+}
+
+// Run implements this command
+func (o *StepVerifyTLSOptions) Run() error {
+	domain := o.Domain
+	if domain == "" {
+		requirements, fileName, err := config.LoadRequirementsConfig(o.Dir)
+		if err != nil {
+			return errors.Wrapf(err, "loading the requirements from directory %s", o.Dir)
+		}
+		domain = requirements.Ingress.Domain
+		if domain == "" {
+			return fmt.Errorf("no ingress domain is configured in %s", fileName)
+		}
+	}
+	address := o.Address
+	if address == "" {
+		address = net.JoinHostPort(domain, "443")
+	}
+
+	log.Logger().Infof("verifying the TLS certificate of %s", util.ColorInfo("https://"+domain))
+	dialer := &net.Dialer{Timeout: o.Timeout}
+	// the certificate is verified below so that we can report why it is invalid
+	conn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{ServerName: domain, InsecureSkipVerify: true}) // #nosec
+	if err != nil {
+		return errors.Wrapf(err, "connecting to %s", address)
+	}
+	defer conn.Close()
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return fmt.Errorf("no certificate was presented by %s", address)
+	}
+	cert := certs[0]
+	log.Logger().Infof("subject: %s", util.ColorInfo(cert.Subject.String()))
+	log.Logger().Infof("issuer:  %s", util.ColorInfo(cert.Issuer.String()))
+	log.Logger().Infof("expires: %s", util.ColorInfo(cert.NotAfter.Format(time.RFC3339)))
+
+	err = verifyCertificate(cert, domain, time.Now(), o.AllowSelfSigned)
+	if err != nil {
+		return err
+	}
+	log.Logger().Infof("the TLS certificate of %s is valid", util.ColorInfo(domain))
+	return nil
+}
+
+// verifyCertificate returns an error if the certificate is not valid at the given time, does not match the domain or,
+// unless allowed, is self-signed
+func verifyCertificate(cert *x509.Certificate, domain string, now time.Time, allowSelfSigned bool) error {
+	if now.After(cert.NotAfter) {
+		return fmt.Errorf("the certificate of %s expired at %s", domain, cert.NotAfter.Format(time.RFC3339))
+	}
+	if now.Before(cert.NotBefore) {
+		return fmt.Errorf("the certificate of %s is not valid until %s", domain, cert.NotBefore.Format(time.RFC3339))
+	}
+	if !allowSelfSigned && isSelfSigned(cert) {
+		return fmt.Errorf("the certificate of %s is self-signed by %s", domain, cert.Issuer.String())
+	}
+	err := cert.VerifyHostname(domain)
+	if err != nil {
+		return errors.Wrapf(err, "the certificate does not match the domain %s", domain)
+	}
+	return nil
+}
+
+// isSelfSigned returns true if the certificate is signed by its own key
+func isSelfSigned(cert *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+		return false
+	}
+	return cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
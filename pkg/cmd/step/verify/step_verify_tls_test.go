@@ -0,0 +1,157 @@
+// +build unit
+
+package verify_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/jx/pkg/cmd/opts"
+	"github.com/jenkins-x/jx/pkg/cmd/opts/step"
+	"github.com/jenkins-x/jx/pkg/cmd/step/verify"
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCA creates a CA certificate which can sign the test certificates
+func testCA(t *testing.T) tls.Certificate {
+	return signTestCertificate(t, nil, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+}
+
+// testCertificate creates a certificate for the DNS names valid until notAfter, signed by the CA or self-signed if
+// the CA is nil
+func testCertificate(t *testing.T, ca *tls.Certificate, notAfter time.Time, dnsNames ...string) tls.Certificate {
+	return signTestCertificate(t, ca, &x509.Certificate{
+		Subject:  pkix.Name{CommonName: dnsNames[0]},
+		DNSNames: dnsNames,
+		NotAfter: notAfter,
+	})
+}
+
+func signTestCertificate(t *testing.T, ca *tls.Certificate, template *x509.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-2 * time.Hour)
+	parent, parentKey := template, interface{}(key)
+	if ca != nil {
+		parent, err = x509.ParseCertificate(ca.Certificate[0])
+		require.NoError(t, err)
+		parentKey = ca.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}
+}
+
+func newTLSTestServer(cert tls.Certificate) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.StartTLS()
+	return server
+}
+
+func TestStepVerifyTLS(t *testing.T) {
+	t.Parallel()
+
+	ca := testCA(t)
+	tests := []struct {
+		name            string
+		cert            tls.Certificate
+		domain          string
+		allowSelfSigned bool
+		wantErr         bool
+	}{
+		{"valid", testCertificate(t, &ca, time.Now().Add(time.Hour), "example.com"), "example.com", false, false},
+		{"wildcard", testCertificate(t, &ca, time.Now().Add(time.Hour), "*.example.com"), "jenkins.example.com", false, false},
+		{"expired", testCertificate(t, &ca, time.Now().Add(-time.Hour), "example.com"), "example.com", false, true},
+		{"other domain", testCertificate(t, &ca, time.Now().Add(time.Hour), "example.com"), "example.org", false, true},
+		{"self-signed", testCertificate(t, nil, time.Now().Add(time.Hour), "example.com"), "example.com", false, true},
+		{"self-signed allowed", testCertificate(t, nil, time.Now().Add(time.Hour), "example.com"), "example.com", true, false},
+	}
+	for _, tt := range tests {
+		server := newTLSTestServer(tt.cert)
+
+		o := &verify.StepVerifyTLSOptions{
+			StepOptions: step.StepOptions{
+				CommonOptions: &opts.CommonOptions{
+					Out: os.Stdout,
+					Err: os.Stderr,
+				},
+			},
+			Domain:          tt.domain,
+			Address:         server.Listener.Addr().String(),
+			AllowSelfSigned: tt.allowSelfSigned,
+			Timeout:         10 * time.Second,
+		}
+		err := o.Run()
+		server.Close()
+		if tt.wantErr {
+			assert.Error(t, err, tt.name)
+		} else {
+			assert.NoError(t, err, tt.name)
+		}
+	}
+}
+
+func TestStepVerifyTLSUsesRequirementsDomain(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "test-step-verify-tls-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	requirements := config.NewRequirementsConfig()
+	requirements.Ingress.Domain = "example.com"
+	err = requirements.SaveConfig(filepath.Join(dir, config.RequirementsConfigFileName))
+	require.NoError(t, err)
+
+	ca := testCA(t)
+	server := newTLSTestServer(testCertificate(t, &ca, time.Now().Add(time.Hour), "example.com"))
+	defer server.Close()
+
+	o := &verify.StepVerifyTLSOptions{
+		StepOptions: step.StepOptions{
+			CommonOptions: &opts.CommonOptions{
+				Out: os.Stdout,
+				Err: os.Stderr,
+			},
+		},
+		Dir:     dir,
+		Address: server.Listener.Addr().String(),
+		Timeout: 10 * time.Second,
+	}
+	err = o.Run()
+	assert.NoError(t, err)
+
+	requirements.Ingress.Domain = "example.org"
+	err = requirements.SaveConfig(filepath.Join(dir, config.RequirementsConfigFileName))
+	require.NoError(t, err)
+	err = o.Run()
+	assert.Error(t, err)
+}
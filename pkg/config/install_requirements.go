@@ -26,6 +26,7 @@ var (
 	// autoDNSSuffixes the DNS suffixes of any auto-DNS services
 	autoDNSSuffixes = []string{
 		".nip.io",
+		".sslip.io",
 		".xip.io",
 		".beesdns.com",
 	}
@@ -241,6 +242,12 @@ type IngressConfig struct {
 	TLS TLSConfig `json:"tls"`
 	// DomainIssuerURL contains a URL used to retrieve a Domain
 	DomainIssuerURL string `json:"domainIssuerURL,omitempty"`
+	// ExternalIP the external IP used to access the ingress controller, recorded when it is discovered from the nodes
+	// of the cluster so that the same node is used on subsequent runs
+	ExternalIP string `json:"externalIP,omitempty"`
+	// ServiceType the type of the ingress controller Service, either NodePort or LoadBalancer. Defaults to NodePort
+	// on the providers without a cloud load balancer
+	ServiceType string `json:"serviceType,omitempty"`
 }
 
 // TLSConfig contains TLS specific requirements
@@ -254,6 +261,15 @@ type TLSConfig struct {
 	Production bool `json:"production"`
 	// SecretName the name of the secret which contains the TLS certificate
 	SecretName string `json:"secretName,omitempty"`
+	// ExistingSecret the name of an existing TLS secret whose certificate covers the domain so that cert-manager
+	// should not issue a new certificate
+	ExistingSecret string `json:"existingSecret,omitempty"`
+	// DNSProvider the DNS provider used by cert-manager to solve DNS-01 challenges, e.g. for internal only domains.
+	// If not specified HTTP-01 challenges are used
+	DNSProvider string `json:"dnsProvider,omitempty"`
+	// DNSCredentialsSecretName the name of the secret in the cert-manager namespace containing the credentials of
+	// the DNS provider
+	DNSCredentialsSecretName string `json:"dnsCredentialsSecretName,omitempty"`
 }
 
 // JxInstallProfile contains the jx profile info
@@ -293,6 +309,20 @@ type AzureConfig struct {
 type GKEConfig struct {
 	// ProjectNumber the unique project number GKE assigns to a project (required for workload identity).
 	ProjectNumber string `json:"projectNumber,omitempty"`
+	// GCloudBinary the path of the gcloud binary used to fetch the credentials of the cluster when it is a remote
+	// cluster, defaults to gcloud on the PATH
+	GCloudBinary string `json:"gcloudBinary,omitempty"`
+	// GCloudArgs additional arguments passed to gcloud when fetching the credentials of the cluster, e.g. --account
+	// or --impersonate-service-account
+	GCloudArgs []string `json:"gcloudArgs,omitempty"`
+	// Autopilot the cluster is a GKE Autopilot cluster, which is always regional
+	Autopilot bool `json:"autopilot,omitempty"`
+}
+
+// IKSConfig contains IKS specific requirements
+type IKSConfig struct {
+	// Private the cluster only has a private service endpoint so the public cluster domain is not reachable
+	Private bool `json:"private,omitempty"`
 }
 
 // ClusterConfig contains cluster specific requirements
@@ -303,6 +333,8 @@ type ClusterConfig struct {
 	ChartRepository string `json:"chartRepository,omitempty"`
 	// GKEConfig the gke specific configuration
 	GKEConfig *GKEConfig `json:"gke,omitempty"`
+	// IKSConfig the iks specific configuration
+	IKSConfig *IKSConfig `json:"iks,omitempty"`
 	// EnvironmentGitOwner the default git owner for environment repositories if none is specified explicitly
 	EnvironmentGitOwner string `json:"environmentGitOwner,omitempty"`
 	// EnvironmentGitPublic determines whether jx boot create public or private git repos for the environments
@@ -334,6 +366,8 @@ type ClusterConfig struct {
 	ExternalDNSSAName string `json:"externalDNSSAName,omitempty"`
 	// Registry the host name of the container registry
 	Registry string `json:"registry,omitempty"`
+	// RegistryService the name of the Service of the in cluster container registry used to default the Registry
+	RegistryService string `json:"registryService,omitempty"`
 	// VaultSAName the service account name for vault
 	// Deprecated
 	VaultSAName string `json:"vaultSAName,omitempty"`
@@ -345,6 +379,9 @@ type ClusterConfig struct {
 	DevEnvApprovers []string `json:"devEnvApprovers,omitempty"`
 	// DockerRegistryOrg the default organisation used for container images
 	DockerRegistryOrg string `json:"dockerRegistryOrg,omitempty"`
+	// KubeContext the name of the context in the local kubeconfig used to connect to this cluster when it is a
+	// remote cluster, rather than using the cloud provider CLI
+	KubeContext string `json:"kubeContext,omitempty"`
 }
 
 // VaultConfig contains Vault configuration for boot
@@ -803,16 +840,28 @@ func (c *RequirementsConfig) handleDeprecation() {
 }
 
 // IsAutoDNSDomain returns true if the domain is configured to use an auto DNS sub domain like
-// '.nip.io' or '.xip.io'
+// '.nip.io', '.sslip.io' or '.xip.io'
 func (i *IngressConfig) IsAutoDNSDomain() bool {
+	return IsAutoDNSDomain(i.Domain)
+}
+
+// IsAutoDNSDomain returns true if the domain is a sub domain of an auto DNS service like '.nip.io', '.sslip.io' or
+// '.xip.io' which resolves the IP address embedded in the domain
+func IsAutoDNSDomain(domain string) bool {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
 	for _, suffix := range autoDNSSuffixes {
-		if strings.HasSuffix(i.Domain, suffix) {
+		if strings.HasSuffix(domain, suffix) {
 			return true
 		}
 	}
 	return false
 }
 
+// IsPrivateIKS returns true if the cluster is an IKS cluster which only has a private service endpoint
+func (c *ClusterConfig) IsPrivateIKS() bool {
+	return c.Provider == cloud.IKS && c.IKSConfig != nil && c.IKSConfig.Private
+}
+
 // OverrideRequirementsFromEnvironment allows properties to be overridden with environment variables
 func (c *RequirementsConfig) OverrideRequirementsFromEnvironment(gcloudFn func() gke.GClouder) {
 	//init envconfig struct tags
@@ -143,6 +143,48 @@ func TestRequirementsConfigIngressAutoDNS(t *testing.T) {
 	assert.Equal(t, false, requirements.Ingress.IsAutoDNSDomain(), "requirements.Ingress.IsAutoDNSDomain() for domain %s", requirements.Ingress.Domain)
 }
 
+func TestIsAutoDNSDomain(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		domain string
+		want   bool
+	}{
+		{"1.2.3.4.nip.io", true},
+		{"1.2.3.4.sslip.io", true},
+		{"1.2.3.4.xip.io", true},
+		{"1.2.3.4.NIP.IO.", true},
+		{"foo.bar", false},
+		{"nip.io.example.com", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, config.IsAutoDNSDomain(tt.domain), "IsAutoDNSDomain(%s)", tt.domain)
+
+		ingress := config.IngressConfig{Domain: tt.domain}
+		assert.Equal(t, tt.want, ingress.IsAutoDNSDomain(), "IngressConfig.IsAutoDNSDomain() for domain %s", tt.domain)
+	}
+}
+
+func TestRequirementsConfigPrivateIKS(t *testing.T) {
+	t.Parallel()
+
+	content, err := ioutil.ReadFile(path.Join(testDataDir, "iks_private.yaml"))
+	require.NoError(t, err)
+
+	requirements := config.NewRequirementsConfig()
+	err = yaml.Unmarshal(content, requirements)
+	require.NoError(t, err)
+	assert.True(t, requirements.Cluster.IsPrivateIKS(), "requirements.Cluster.IsPrivateIKS()")
+
+	requirements.Cluster.IKSConfig.Private = false
+	assert.False(t, requirements.Cluster.IsPrivateIKS(), "requirements.Cluster.IsPrivateIKS()")
+
+	requirements.Cluster.IKSConfig.Private = true
+	requirements.Cluster.Provider = cloud.GKE
+	assert.False(t, requirements.Cluster.IsPrivateIKS(), "requirements.Cluster.IsPrivateIKS()")
+}
+
 func Test_env_repository_visibility(t *testing.T) {
 	t.Parallel()
 
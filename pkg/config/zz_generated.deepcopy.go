@@ -268,6 +268,11 @@ func (in *ClusterConfig) DeepCopyInto(out *ClusterConfig) {
 	if in.GKEConfig != nil {
 		in, out := &in.GKEConfig, &out.GKEConfig
 		*out = new(GKEConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IKSConfig != nil {
+		in, out := &in.IKSConfig, &out.IKSConfig
+		*out = new(IKSConfig)
 		**out = **in
 	}
 	if in.DevEnvApprovers != nil {
@@ -364,6 +369,11 @@ func (in *ExposeControllerConfig) DeepCopy() *ExposeControllerConfig {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GKEConfig) DeepCopyInto(out *GKEConfig) {
 	*out = *in
+	if in.GCloudArgs != nil {
+		in, out := &in.GCloudArgs, &out.GCloudArgs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -482,6 +492,22 @@ func (in *HelmValuesConfigService) DeepCopy() *HelmValuesConfigService {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IKSConfig) DeepCopyInto(out *IKSConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IKSConfig.
+func (in *IKSConfig) DeepCopy() *IKSConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(IKSConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Image) DeepCopyInto(out *Image) {
 	*out = *in
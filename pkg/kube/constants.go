@@ -293,6 +293,9 @@ const (
 	// AnnotationReleaseName is the name of the annotation that stores the release name in the preview environment
 	AnnotationReleaseName = "jenkins.io/chart-release"
 
+	// AnnotationPrimaryContainer the name of the main container of a deployment which has several containers
+	AnnotationPrimaryContainer = "jenkins.io/primary-container"
+
 	// SecretDataUsername the username in a Secret/Credentials
 	SecretDataUsername = "username"
 
@@ -1,9 +1,40 @@
 package kube
 
 import (
+	"strings"
+
 	corev1 "k8s.io/api/core/v1"
 )
 
+// DefaultImageRegistry the registry used for container images which do not specify one
+const DefaultImageRegistry = "docker.io"
+
+// ImageRegistry returns the registry host of the container image, defaulting to docker.io for images such as
+// 'nginx' or 'jenkinsxio/jx' which do not specify a registry
+func ImageRegistry(image string) string {
+	i := strings.Index(image, "/")
+	if i < 0 {
+		return DefaultImageRegistry
+	}
+	host := image[:i]
+	if host == "localhost" || strings.ContainsAny(host, ".:") {
+		return host
+	}
+	return DefaultImageRegistry
+}
+
+// ImageTag returns the tag of the container image, or an empty string if the image does not specify a tag
+func ImageTag(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return ""
+	}
+	return image[i+1:]
+}
+
 // GetEnvVar returns the env var if its defined for the given name
 func GetEnvVar(container *corev1.Container, name string) *corev1.EnvVar {
 	if container == nil {
@@ -119,6 +119,75 @@ func FindServiceURL(client kubernetes.Interface, namespace string, name string)
 	return url, nil
 }
 
+// FindServiceProtocolURL finds the service URL like FindServiceURL but uses a grpc:// or h2c:// scheme for services
+// exposing gRPC or cleartext HTTP/2 ports and the in-cluster DNS name for headless services
+func FindServiceProtocolURL(client kubernetes.Interface, namespace string, name string) (string, error) {
+	svc, err := client.CoreV1().Services(namespace).Get(name, meta_v1.GetOptions{})
+	if err != nil {
+		return "", errors.Wrapf(err, "finding the service %s in namespace %s", name, namespace)
+	}
+	if svc.Spec.ClusterIP == v1.ClusterIPNone || GetServiceURL(svc) != "" {
+		return ServiceProtocolURL(svc, nil), nil
+	}
+
+	// lets try find the service via Ingress
+	ing, err := client.ExtensionsV1beta1().Ingresses(namespace).Get(name, meta_v1.GetOptions{})
+	if err != nil {
+		return "", errors.Wrapf(err, "getting ingress for service %q in namespace %s", name, namespace)
+	}
+	return ServiceProtocolURL(svc, ing), nil
+}
+
+// ServiceProtocolURL returns the URL of the service, or of the ingress of the same name if the service has no
+// external URL, like FindServiceProtocolURL for a service and ingress which have already been fetched
+func ServiceProtocolURL(svc *v1.Service, ing *v1beta1.Ingress) string {
+	scheme, port := serviceProtocolSchemePort(svc)
+	if svc.Spec.ClusterIP == v1.ClusterIPNone {
+		if scheme == "" {
+			scheme = "http"
+		}
+		if port == 0 && len(svc.Spec.Ports) > 0 {
+			port = svc.Spec.Ports[0].Port
+		}
+		host := fmt.Sprintf("%s.%s.svc.cluster.local", svc.Name, svc.Namespace)
+		if port > 0 {
+			host += ":" + strconv.Itoa(int(port))
+		}
+		return scheme + "://" + host
+	}
+
+	url := GetServiceURL(svc)
+	if url == "" {
+		url = IngressURL(ing)
+	}
+	if url == "" || scheme == "" {
+		return url
+	}
+	idx := strings.Index(url, "://")
+	if idx < 0 {
+		return url
+	}
+	return scheme + url[idx:]
+}
+
+// serviceProtocolSchemePort returns the grpc or h2c scheme and port of the first service port whose name follows the
+// protocol selection naming convention, e.g. grpc, grpc-web, http2 or h2c, otherwise an empty scheme
+func serviceProtocolSchemePort(svc *v1.Service) (string, int32) {
+	hasPrefix := func(name, protocol string) bool {
+		return name == protocol || strings.HasPrefix(name, protocol+"-")
+	}
+	for _, p := range svc.Spec.Ports {
+		name := strings.ToLower(p.Name)
+		if hasPrefix(name, "grpc") {
+			return "grpc", p.Port
+		}
+		if hasPrefix(name, "http2") || hasPrefix(name, "h2c") {
+			return "h2c", p.Port
+		}
+	}
+	return "", 0
+}
+
 func FindIngressURL(client kubernetes.Interface, namespace string, name string) (string, error) {
 	log.Logger().Debugf("Finding ingress url for %s in namespace %s", name, namespace)
 	// lets try find the service via Ingress
@@ -7,8 +7,10 @@ import (
 
 	"github.com/jenkins-x/jx/pkg/kube/services"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestExtractServiceSchemePortDefault(t *testing.T) {
@@ -288,3 +290,56 @@ func TestExtractServiceSchemePortInconclusive(t *testing.T) {
 	assert.Equal(t, "", schema)
 	assert.Equal(t, "", port)
 }
+
+func TestFindServiceProtocolURL(t *testing.T) {
+	t.Parallel()
+
+	loadBalancerService := func(name string, ports ...v1.ServicePort) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "jx-staging",
+			},
+			Spec: v1.ServiceSpec{
+				Type:  v1.ServiceTypeLoadBalancer,
+				Ports: ports,
+			},
+			Status: v1.ServiceStatus{
+				LoadBalancer: v1.LoadBalancerStatus{
+					Ingress: []v1.LoadBalancerIngress{{IP: "1.2.3.4"}},
+				},
+			},
+		}
+	}
+	headless := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-headless",
+			Namespace: "jx-staging",
+		},
+		Spec: v1.ServiceSpec{
+			ClusterIP: v1.ClusterIPNone,
+			Ports: []v1.ServicePort{
+				{Name: "metrics", Protocol: "TCP", Port: 9090},
+				{Name: "grpc-api", Protocol: "TCP", Port: 9000},
+			},
+		},
+	}
+	client := fake.NewSimpleClientset(
+		loadBalancerService("my-http", v1.ServicePort{Name: "http", Protocol: "TCP", Port: 80}),
+		loadBalancerService("my-grpc", v1.ServicePort{Name: "grpc", Protocol: "TCP", Port: 80}),
+		loadBalancerService("my-h2c", v1.ServicePort{Name: "http2-web", Protocol: "TCP", Port: 80}),
+		headless,
+	)
+
+	testCases := map[string]string{
+		"my-http":     "http://1.2.3.4/",
+		"my-grpc":     "grpc://1.2.3.4/",
+		"my-h2c":      "h2c://1.2.3.4/",
+		"my-headless": "grpc://my-headless.jx-staging.svc.cluster.local:9000",
+	}
+	for name, expected := range testCases {
+		url, err := services.FindServiceProtocolURL(client, "jx-staging", name)
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, url, name)
+	}
+}